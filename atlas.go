@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/faiface/mainthread"
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// atlasSize is the width and height of the sprite atlas. It has to fit the
+// static sprites and the sprites of the largest map.
+const atlasSize = 2048
+
+// atlasPadding is the space between two sprites in the atlas. It keeps
+// sprites from bleeding into each other.
+const atlasPadding = 1
+
+// spriteAtlas packs many sprites into one canvas so they can all be drawn
+// through a single batch. Sprites are placed on shelves: left to right in
+// rows, starting a new row when the current one is full.
+type spriteAtlas struct {
+	canvas *pixelgl.Canvas
+
+	x, y, rowHeight float64
+}
+
+// atlasMark is the state of an atlas that it can be reset to.
+type atlasMark struct {
+	x, y, rowHeight float64
+}
+
+func newSpriteAtlas(size float64) *spriteAtlas {
+	return &spriteAtlas{
+		canvas: pixelgl.NewCanvas(pixel.R(0, 0, size, size)),
+	}
+}
+
+// alloc reserves space for a sprite of the given size.
+func (a *spriteAtlas) alloc(w, h int) pixel.Rect {
+	size := a.canvas.Bounds().Size()
+	if a.x+float64(w) > size.X {
+		a.x = 0
+		a.y += a.rowHeight + atlasPadding
+		a.rowHeight = 0
+	}
+	if a.x+float64(w) > size.X || a.y+float64(h) > size.Y {
+		panic(fmt.Sprintf("sprite atlas is full, cannot fit %dx%d", w, h))
+	}
+
+	frame := pixel.R(a.x, a.y, a.x+float64(w), a.y+float64(h))
+	a.x += float64(w) + atlasPadding
+	if float64(h) > a.rowHeight {
+		a.rowHeight = float64(h)
+	}
+	return frame
+}
+
+// add puts a sprite with the given pixels into the atlas. The pixels must
+// be alpha-premultiplied RGBA.
+func (a *spriteAtlas) add(pixels []uint8, w, h int) *pixel.Sprite {
+	frame := a.alloc(w, h)
+	a.set(frame, pixels)
+	return pixel.NewSprite(a.canvas, frame)
+}
+
+// addCanvas copies the content of c into the atlas.
+func (a *spriteAtlas) addCanvas(c *pixelgl.Canvas) *pixel.Sprite {
+	size := c.Bounds().Size()
+	return a.add(c.Pixels(), int(size.X), int(size.Y))
+}
+
+// set replaces the pixels inside frame. This is used to animate sprites
+// without touching the rest of the atlas.
+func (a *spriteAtlas) set(frame pixel.Rect, pixels []uint8) {
+	mainthread.Call(func() {
+		tex := a.canvas.Texture()
+		tex.Begin()
+		tex.SetPixels(int(frame.Min.X), int(frame.Min.Y), int(frame.W()), int(frame.H()), pixels)
+		tex.End()
+	})
+}
+
+// mark returns the current state of the atlas.
+func (a *spriteAtlas) mark() atlasMark {
+	return atlasMark{a.x, a.y, a.rowHeight}
+}
+
+// reset removes all sprites that were added after m was taken. Their
+// space is reused by the following sprites.
+func (a *spriteAtlas) reset(m atlasMark) {
+	a.x, a.y, a.rowHeight = m.x, m.y, m.rowHeight
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+
+	"github.com/dbriemann/gonk/noise"
+)
+
+const (
+	// starLayerSize is the size of one tile of a star layer.
+	starLayerSize = 1024
+	// The nebula is generated at a quarter of the size it is drawn with.
+	nebulaSize  = 512
+	nebulaScale = 4
+)
+
+// The noise of the nebula's cloud density and of the mix of its two hues.
+var (
+	nebulaDensity = noise.Fractal{Octaves: 6, Frequency: 0.005, Persistence: 0.5}
+	nebulaMix     = noise.Fractal{Octaves: 3, Frequency: 0.01, Persistence: 0.5}
+)
+
+// bgLayer is a cached canvas of the background. Layers are drawn tiled
+// and move with a fraction (parallax) of the camera movement.
+type bgLayer struct {
+	canvas   *pixelgl.Canvas
+	parallax float64
+	scale    float64
+}
+
+// background is the starfield and nebula behind the world.
+type background struct {
+	// Layers from back to front.
+	layers []bgLayer
+}
+
+// genBackground generates the background for a seed. The same seed always
+// gives the same background.
+func genBackground(seed int64) *background {
+	rng := rand.New(rand.NewSource(seed))
+	n := noise.New(seed)
+
+	bg := &background{}
+	bg.layers = append(bg.layers, bgLayer{genNebula(rng, n), 0.05, nebulaScale})
+	for i, parallax := range []float64{0.1, 0.2, 0.4} {
+		// Nearer layers have fewer but brighter stars.
+		bg.layers = append(bg.layers, bgLayer{genStars(rng, 1500/(i+1), 0.4+0.3*float64(i)), parallax, 1})
+	}
+	return bg
+}
+
+// genStars generates one tile of a star layer with the given amount of
+// stars. Star brightness is randomized up to maxBrightness.
+func genStars(rng *rand.Rand, amount int, maxBrightness float64) (canvas *pixelgl.Canvas) {
+	canvas = pixelgl.NewCanvas(pixel.R(0, 0, starLayerSize, starLayerSize))
+	pixels := canvas.Pixels()
+
+	for i := 0; i < amount; i++ {
+		x, y := rng.Intn(starLayerSize), rng.Intn(starLayerSize)
+		b := maxBrightness * (0.3 + 0.7*rng.Float64())
+		// Most stars are white, some are slightly tinted.
+		col := pixel.RGB(b, b, b)
+		switch rng.Intn(10) {
+		case 0:
+			col = pixel.RGB(b, b*0.8, b*0.6)
+		case 1:
+			col = pixel.RGB(b*0.7, b*0.8, b)
+		}
+
+		index := y*starLayerSize*4 + x*4
+		pixels[index] = uint8(col.R * 255)
+		pixels[index+1] = uint8(col.G * 255)
+		pixels[index+2] = uint8(col.B * 255)
+		pixels[index+3] = 255
+	}
+
+	canvas.SetPixels(pixels)
+
+	return
+}
+
+// genNebula generates colored clouds from two layers of noise. The noise
+// is tileable so tiling the clouds does not show seams.
+func genNebula(rng *rand.Rand, n *noise.Noise) (canvas *pixelgl.Canvas) {
+	canvas = pixelgl.NewCanvas(pixel.R(0, 0, nebulaSize, nebulaSize))
+	canvas.SetSmooth(true)
+	pixels := canvas.Pixels()
+
+	// Two random hues that are mixed by the second noise layer.
+	hues := [2]pixel.RGBA{
+		pixel.RGB(0.2+0.4*rng.Float64(), 0.1*rng.Float64(), 0.3+0.5*rng.Float64()),
+		pixel.RGB(0.1*rng.Float64(), 0.2+0.4*rng.Float64(), 0.3+0.5*rng.Float64()),
+	}
+	ox, oy := rng.Float64()*1000, rng.Float64()*1000
+
+	for y := 0; y < nebulaSize; y++ {
+		for x := 0; x < nebulaSize; x++ {
+			density := noise.Map(n.Tileable2(nebulaDensity, float64(x)+ox, float64(y)+oy, nebulaSize, nebulaSize), -0.6, 1)
+			mix := noise.Map(n.Tileable2(nebulaMix, float64(y)+oy, float64(x)+ox, nebulaSize, nebulaSize), 0, 1)
+
+			a := math.Max(0, density) * 0.5
+			col := hues[0].Scaled(1 - mix).Add(hues[1].Scaled(mix)).Scaled(a)
+
+			index := y*nebulaSize*4 + x*4
+			pixels[index] = uint8(col.R * 255)
+			pixels[index+1] = uint8(col.G * 255)
+			pixels[index+2] = uint8(col.B * 255)
+			pixels[index+3] = uint8(a * 255)
+		}
+	}
+
+	canvas.SetPixels(pixels)
+
+	return
+}
+
+// draw draws all layers to t, covering the rectangle bounds. The layers
+// are shifted according to the camera position.
+func (bg *background) draw(t pixel.Target, bounds pixel.Rect) {
+	for _, l := range bg.layers {
+		size := l.canvas.Bounds().W() * l.scale
+		// The position of one tile's center in window coordinates.
+		center := bounds.Center().Sub(camPos.Scaled(l.parallax))
+		// Shift it into the first tile that touches the lower left corner.
+		first := pixel.V(
+			center.X-math.Floor((center.X-bounds.Min.X+size/2)/size)*size,
+			center.Y-math.Floor((center.Y-bounds.Min.Y+size/2)/size)*size,
+		)
+		for x := first.X; x-size/2 < bounds.Max.X; x += size {
+			for y := first.Y; y-size/2 < bounds.Max.Y; y += size {
+				l.canvas.Draw(t, pixel.IM.Scaled(pixel.ZV, l.scale).Moved(pixel.V(x, y)))
+			}
+		}
+	}
+}
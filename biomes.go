@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math/rand"
+
+	"github.com/faiface/pixel"
+)
+
+// biome is the type of a planet's surface.
+type biome int
+
+const (
+	biomeRocky biome = iota
+	biomeOcean
+	biomeIce
+	biomeLava
+	biomeDesert
+	biomeGasGiant
+	biomeCount
+)
+
+func (b biome) String() string {
+	return [biomeCount]string{
+		biomeRocky:    "rocky",
+		biomeOcean:    "ocean",
+		biomeIce:      "ice",
+		biomeLava:     "lava",
+		biomeDesert:   "desert",
+		biomeGasGiant: "gas giant",
+	}[b]
+}
+
+// colorStop is a color at a position (0-1) of a gradient.
+type colorStop struct {
+	at  float64
+	col pixel.RGBA
+}
+
+// gradient maps values from 0 to 1 to colors by interpolating linearly
+// between its stops. The stops must be sorted by position.
+type gradient []colorStop
+
+// at returns the color of the gradient at t.
+func (g gradient) at(t float64) pixel.RGBA {
+	return g.eased(t, easeLinear)
+}
+
+// eased returns the color of the gradient at t. The transition between
+// two neighboring stops is shaped by e instead of being linear.
+func (g gradient) eased(t float64, e easing) pixel.RGBA {
+	if t <= g[0].at {
+		return g[0].col
+	}
+	for i := 1; i < len(g); i++ {
+		if t <= g[i].at {
+			f := e.apply((t - g[i-1].at) / (g[i].at - g[i-1].at))
+			return g[i-1].col.Scaled(1 - f).Add(g[i].col.Scaled(f))
+		}
+	}
+	return g[len(g)-1].col
+}
+
+// rgb is a shorthand for opaque colors given in 0-255 components.
+func rgb(r, g, b uint8) pixel.RGBA {
+	return pixel.RGB(float64(r)/255, float64(g)/255, float64(b)/255)
+}
+
+// biomeGradients map the noise height of a planet's surface to colors.
+// For gas giants the value is the latitude band instead of a height.
+var biomeGradients = [biomeCount]gradient{
+	biomeRocky: {
+		{0.3, rgb(60, 50, 45)},
+		{0.5, rgb(120, 105, 95)},
+		{0.7, rgb(170, 160, 150)},
+		{0.85, rgb(220, 215, 210)},
+	},
+	biomeOcean: {
+		{0.3, rgb(10, 30, 90)},
+		{0.55, rgb(30, 90, 170)},
+		{0.58, rgb(210, 200, 150)},
+		{0.65, rgb(60, 140, 60)},
+		{0.8, rgb(30, 90, 40)},
+		{0.9, rgb(240, 240, 240)},
+	},
+	biomeIce: {
+		{0.3, rgb(120, 160, 200)},
+		{0.55, rgb(190, 220, 240)},
+		{0.8, rgb(250, 250, 255)},
+	},
+	biomeLava: {
+		{0.3, rgb(255, 230, 80)},
+		{0.45, rgb(240, 100, 20)},
+		{0.55, rgb(120, 20, 10)},
+		{0.7, rgb(40, 30, 30)},
+		{0.85, rgb(70, 60, 55)},
+	},
+	biomeDesert: {
+		{0.3, rgb(150, 80, 40)},
+		{0.5, rgb(210, 150, 90)},
+		{0.7, rgb(235, 200, 140)},
+		{0.85, rgb(250, 230, 190)},
+	},
+	biomeGasGiant: {
+		{0.3, rgb(150, 100, 70)},
+		{0.4, rgb(220, 190, 150)},
+		{0.5, rgb(190, 120, 80)},
+		{0.6, rgb(240, 220, 190)},
+		{0.7, rgb(170, 110, 80)},
+		{0.8, rgb(230, 200, 160)},
+	},
+}
+
+// biomeAtmospheres are the colors of the atmosphere rims. Biomes with a
+// transparent color have no atmosphere.
+var biomeAtmospheres = [biomeCount]pixel.RGBA{
+	biomeRocky:    pixel.Alpha(0),
+	biomeOcean:    rgb(120, 180, 255),
+	biomeIce:      rgb(200, 230, 255).Scaled(0.7),
+	biomeLava:     rgb(255, 120, 40).Scaled(0.6),
+	biomeDesert:   rgb(240, 200, 150).Scaled(0.5),
+	biomeGasGiant: rgb(240, 220, 180).Scaled(0.8),
+}
+
+// randomBiome returns a random biome. Satellites are never gas giants.
+func randomBiome(satellite bool) biome {
+	if satellite {
+		return biome(rand.Intn(int(biomeGasGiant)))
+	}
+	return biome(rand.Intn(int(biomeCount)))
+}
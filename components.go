@@ -2,6 +2,7 @@ package main
 
 import (
 	"image/color"
+	"math"
 
 	"github.com/faiface/pixel"
 )
@@ -13,24 +14,131 @@ type player struct {
 	color color.Color
 }
 
-type orb struct {
-	anchor *pixel.Vec
-	vel    pixel.Vec
-	pos    pixel.Vec
-	dir    float64
-	dist   float64
+// orbit describes an elliptical Keplerian orbit around an anchor. The
+// anchor is in one focus of the ellipse.
+type orbit struct {
+	semiMajor    float64
+	eccentricity float64
+	// periapsis is the angle (rad) of the point closest to the anchor.
+	periapsis float64
+	// phase is the mean anomaly (rad) at time 0.
+	phase float64
+	// period is the time (s) of one revolution.
+	period float64
+	// dir is 1 for counter-clockwise and -1 for clockwise orbits.
+	dir float64
 }
 
-// rotate rotates an orb around its anchor and returns the shift vector.
-// The angle is destined by the orb's velocity and distance to the anchor.
-func (o *orb) rotate(dt float64) (shift pixel.Vec) {
-	mat := pixel.IM
-	len := o.vel.Len()
-	omega := o.dir * len / o.dist
+// offset returns the position at time t relative to the anchor. The
+// angular speed follows Kepler's second law: the body is faster the
+// closer it is to the anchor.
+func (o orbit) offset(t float64) pixel.Vec {
+	if o.semiMajor == 0 {
+		return pixel.ZV
+	}
+	mean := o.phase + o.dir*2*math.Pi*t/o.period
+	ecc := eccentricAnomaly(mean, o.eccentricity)
+	semiMinor := o.semiMajor * math.Sqrt(1-o.eccentricity*o.eccentricity)
 
-	mat = mat.Rotated(*o.anchor, omega*dt)
-	npos := mat.Project(o.pos)
-	shift.X, shift.Y = npos.X-o.pos.X, npos.Y-o.pos.Y
-	o.pos.X, o.pos.Y = npos.XY()
-	return
+	v := pixel.V(o.semiMajor*(math.Cos(ecc)-o.eccentricity), semiMinor*math.Sin(ecc))
+	return v.Rotated(o.periapsis)
+}
+
+// keplerPeriod returns the orbital period around an anchor of the given
+// mass by Kepler's third law: T² = 4π²a³/(GM).
+func keplerPeriod(semiMajor, mass float64) float64 {
+	return 2 * math.Pi * math.Sqrt(semiMajor*semiMajor*semiMajor/(gravity*mass))
+}
+
+// eccentricAnomaly solves Kepler's equation M = E - e*sin(E) for E with
+// Newton's method.
+func eccentricAnomaly(mean, e float64) float64 {
+	mean = math.Mod(mean, 2*math.Pi)
+	ecc := mean
+	if e > 0.8 {
+		ecc = math.Pi
+	}
+	for i := 0; i < 10; i++ {
+		delta := (ecc - e*math.Sin(ecc) - mean) / (1 - e*math.Cos(ecc))
+		ecc -= delta
+		if math.Abs(delta) < 1e-9 {
+			break
+		}
+	}
+	return ecc
+}
+
+// body is a node in the orbital hierarchy. It orbits its parent, so the
+// world position is the sum of the offsets along the parent chain. This
+// allows moons of moons and bodies orbiting a common barycenter. A body
+// with a zero orbit stays at its parent's position.
+type body struct {
+	orbit
+	parent   *body
+	children []*body
+	// pos is the world position at the time of the last update.
+	pos pixel.Vec
+}
+
+// attach makes child orbit b. A child that orbits another body is
+// detached from it first. A body can't orbit itself or one of its own
+// satellites, so such calls are ignored.
+func (b *body) attach(child *body) {
+	if child == nil || child.parent == b || child == b || b.orbits(child) {
+		return
+	}
+	if child.parent != nil {
+		child.parent.detach(child)
+	}
+	child.parent = b
+	b.children = append(b.children, child)
+}
+
+// detach removes child from the children of b.
+func (b *body) detach(child *body) {
+	for i, c := range b.children {
+		if c == child {
+			b.children = append(b.children[:i], b.children[i+1:]...)
+			child.parent = nil
+			return
+		}
+	}
+}
+
+// orbits reports whether b orbits o, directly or as a satellite of one
+// of o's children.
+func (b *body) orbits(o *body) bool {
+	for p := b.parent; p != nil; p = p.parent {
+		if p == o {
+			return true
+		}
+	}
+	return false
+}
+
+// at returns the world position at time t.
+func (b *body) at(t float64) pixel.Vec {
+	pos := b.offset(t)
+	if b.parent != nil {
+		pos = pos.Add(b.parent.at(t))
+	}
+	return pos
+}
+
+// velocity returns the velocity in world coordinates at time t.
+func (b *body) velocity(t float64) pixel.Vec {
+	const h = 0.01
+	return b.at(t + h).Sub(b.at(t - h)).Scaled(1 / (2 * h))
+}
+
+// move moves b and all its descendants to their positions at time t.
+// Parents are always updated before their children.
+func (b *body) move(t float64) {
+	b.pos = b.offset(t)
+	if b.parent != nil {
+		b.pos = b.pos.Add(b.parent.pos)
+	}
+	for _, c := range b.children {
+		c.move(t)
+	}
 }
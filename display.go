@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// displayMode determines how the window is shown.
+type displayMode int
+
+const (
+	displayWindowed displayMode = iota
+	displayFullscreen
+	// displayBorderless covers the whole monitor in its current video mode.
+	// Unlike displayFullscreen it never changes the video mode.
+	displayBorderless
+)
+
+func (d displayMode) String() string {
+	switch d {
+	case displayFullscreen:
+		return "Fullscreen"
+	case displayBorderless:
+		return "Borderless"
+	default:
+		return "Windowed"
+	}
+}
+
+// resolutions are the window sizes that can be chosen. In fullscreen mode
+// they select the video mode of the monitor.
+var resolutions = [][2]int{
+	{1024, 768},
+	{1200, 800},
+	{1280, 720},
+	{1366, 768},
+	{1600, 900},
+	{1920, 1080},
+	{2560, 1440},
+	{3840, 2160},
+}
+
+// selectedMonitor returns the monitor at monitorIndex. If that monitor does
+// not exist (anymore) the primary monitor is returned.
+func selectedMonitor() *pixelgl.Monitor {
+	ms := pixelgl.Monitors()
+	if monitorIndex >= 0 && monitorIndex < len(ms) {
+		return ms[monitorIndex]
+	}
+	return primaryMonitor
+}
+
+// createWindow creates the window and switches it to the current display
+// settings. It must only be called once: all GL resources belong to the
+// window, so later changes are made by setDisplay on the same window.
+func createWindow() error {
+	win, err := pixelgl.NewWindow(pixelgl.WindowConfig{
+		Title:     title,
+		Bounds:    pixel.R(0, 0, float64(screenWidth), float64(screenHeight)),
+		Resizable: true,
+		VSync:     vsync,
+	})
+	if err != nil {
+		return err
+	}
+	window = win
+	applyDisplay(selectedMonitor())
+	return nil
+}
+
+// applyDisplay switches the window to the current display settings on
+// monitor m.
+func applyDisplay(m *pixelgl.Monitor) {
+	// Always leave fullscreen first, so the window remembers its windowed
+	// position and size and not the one of the previous fullscreen mode.
+	window.SetMonitor(nil)
+	window.SetVSync(vsync)
+
+	switch display {
+	case displayFullscreen:
+		// Pixel switches to the monitor's current video mode. Setting the
+		// bounds selects the requested one instead.
+		window.SetMonitor(m)
+		window.SetBounds(pixel.R(0, 0, float64(screenWidth), float64(screenHeight)))
+	case displayBorderless:
+		// The bounds are not updated before the next frame otherwise.
+		window.SetMonitor(m)
+		w, h := m.Size()
+		window.SetBounds(pixel.R(0, 0, w, h))
+	default:
+		window.SetBounds(pixel.R(0, 0, float64(screenWidth), float64(screenHeight)))
+	}
+}
+
+// setDisplay applies new display settings to the window. If they can't be
+// applied an error is returned and the previous settings are kept.
+func setDisplay(mode displayMode, monitor, width, height int, vsyncOn bool) error {
+	ms := pixelgl.Monitors()
+	if monitor < 0 || monitor >= len(ms) {
+		return fmt.Errorf("monitor %d is not connected", monitor+1)
+	}
+
+	display = mode
+	monitorIndex = monitor
+	screenWidth, screenHeight = width, height
+	vsync = vsyncOn
+
+	applyDisplay(ms[monitor])
+	handleResize()
+	return nil
+}
+
+// handleResize adapts everything that depends on the window size. It has
+// to be called whenever the window bounds change.
+func handleResize() {
+	worldCanvas.SetBounds(window.Bounds())
+	if display == displayWindowed {
+		// Remember the size the user dragged the window to.
+		screenWidth, screenHeight = int(window.Bounds().W()), int(window.Bounds().H())
+	}
+
+	// Set the camera to look at camPos.
+	cam = pixel.IM.Moved(worldCanvas.Bounds().Center().Sub(camPos))
+	worldCanvas.SetMatrix(cam)
+
+	initHUD()
+	if state == stateMenu {
+		menuScreen()
+	}
+}
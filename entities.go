@@ -2,45 +2,74 @@ package main
 
 import (
 	"math"
+	"math/rand"
 
 	"github.com/faiface/pixel"
-	"github.com/faiface/pixel/pixelgl"
+
+	"github.com/dbriemann/gonk/noise"
 )
 
 type planet struct {
-	orb
+	body
 	*player
 
-	satellites    []*planet
 	ships         []*ship
 	shipsProduced float64
 	shipAngleMod  float64
 	radius        float64
+	mass          float64
+	biome         biome
+	ring          *ring
+
+	texture *planetTexture
+}
+
+// ring is a ring system around a planet. It is drawn as a tilted ellipse
+// which makes it easy to tell apart from the circle of stationed ships.
+type ring struct {
+	back, front *pixel.Sprite
+	// tilt is the ratio of the ellipse's minor to major axis.
+	tilt  float64
+	angle float64
+}
+
+// ringTint returns the color of the rings of a planet with the given biome.
+// Rings are icy or dusty, tinted slightly by the planet.
+func ringTint(biome biome) pixel.RGBA {
+	if biome == biomeIce || biome == biomeGasGiant {
+		return rgb(215, 220, 230)
+	}
+	return rgb(200, 190, 175)
+}
 
-	sprite *pixelgl.Canvas
+// newRing creates a random ring system for a planet with the given biome.
+func newRing(biome biome) *ring {
+	r := &ring{
+		tilt:  0.25 + rand.Float64()*0.25,
+		angle: (rand.Float64() - 0.5) * 0.6,
+	}
+	seed := rand.Int63()
+	back, front := genRing(noise.New(seed), 30, 1.3, 1.8, ringTint(biome))
+	r.back = sprites.atlas.addCanvas(back)
+	r.front = sprites.atlas.addCanvas(front)
+	return r
 }
 
-func newPlanet(dist, radius, dir float64, vel pixel.Vec, anchor *pixel.Vec, player *player, sprite *pixelgl.Canvas) *planet {
+// newPlanet creates a planet without a texture. The texture has to be set
+// before the planet is drawn.
+func newPlanet(o orbit, radius float64, parent *body, player *player, biome biome) *planet {
 	p := &planet{
-		orb: orb{
-			dist:   dist,
-			anchor: anchor,
-			vel:    vel,
-			dir:    dir,
-		},
-		player:     player,
-		radius:     radius,
-		satellites: []*planet{},
-		ships:      make([]*ship, int(radius/3)),
-		sprite:     sprite,
-	}
-
-	if anchor != nil {
-		p.pos.X = anchor.X + dist
-	} else {
-		p.pos.X = dist
+		body:   body{orbit: o},
+		player: player,
+		radius: radius,
+		mass:   radius * radius * radius * planetDensity,
+		biome:  biome,
+		ships:  make([]*ship, int(radius/3)),
 	}
 
+	parent.attach(&p.body)
+	p.pos = p.at(gameTime)
+
 	for i := 0; i < len(p.ships); i++ {
 		p.ships[i] = newShip(p, player)
 	}
@@ -51,17 +80,10 @@ func newPlanet(dist, radius, dir float64, vel pixel.Vec, anchor *pixel.Vec, play
 	return p
 }
 
-// rotateGroup rotates the planet and adjusts the position of its satellites accordingly.
-func (p *planet) rotateGroup(dt float64) {
-	dvec := p.rotate(dt)
-	for i := 0; i < len(p.satellites); i++ {
-		p.satellites[i].pos.X += dvec.X
-		p.satellites[i].pos.Y += dvec.Y
-	}
-}
-
 func (p *planet) update(dt float64) {
-	p.rotateGroup(dt)
+	if rotatePlanets {
+		p.texture.rotate(gameTime * planetRotationSpeed)
+	}
 	// Ship production depends on planet size: production = sqrt(radius)/5
 	prod := math.Sqrt(p.radius) * productionFactor
 	p.shipsProduced += prod * dt
@@ -88,7 +110,33 @@ func (p *planet) update(dt float64) {
 
 func (p *planet) draw(translation pixel.Matrix) {
 	// TODO magic numbers
-	p.sprite.DrawColorMask(worldCanvas, pixel.IM.Moved(p.pos).Scaled(p.pos, p.radius/30), nil)
+	scale := p.radius / 30
+	// Atmosphere and shadow face the nearest star, also for satellites.
+	// Without a star there is no light to show them.
+	light := nearestStar(p.pos)
+	var lit pixel.Matrix
+	if light != nil {
+		lit = pixel.IM.Rotated(pixel.ZV, light.pos.Sub(p.pos).Angle()).Moved(p.pos).Scaled(p.pos, scale)
+	}
+
+	// The ring is split so the planet covers its back half.
+	var ringMat pixel.Matrix
+	if p.ring != nil {
+		ringMat = pixel.IM.ScaledXY(pixel.ZV, pixel.V(1, p.ring.tilt)).Rotated(pixel.ZV, p.ring.angle).Moved(p.pos).Scaled(p.pos, scale)
+		p.ring.back.Draw(batches.planets, ringMat)
+	}
+	if atmo := biomeAtmospheres[p.biome]; atmo.A > 0 && light != nil {
+		sprites.atmosphere.DrawColorMask(batches.planets, lit, atmo)
+	}
+
+	p.texture.sprite.Draw(batches.planets, pixel.IM.Moved(p.pos).Scaled(p.pos, scale))
+	if light != nil {
+		sprites.shadow.Draw(batches.planets, lit)
+	}
+
+	if p.ring != nil {
+		p.ring.front.Draw(batches.planets, ringMat)
+	}
 
 	// Draw all ships stationed at this planet.
 	for _, s := range p.ships {
@@ -114,9 +162,87 @@ func (p *planet) setShips(dt float64) {
 	}
 }
 
+// star is a sun of the solar system.
+type star struct {
+	body
+	radius float64
+	mass   float64
+	class  starClass
+	sprite *sunTexture
+}
+
+func newStar(o orbit, parent *body, class starClass) *star {
+	s := &star{
+		body:   body{orbit: o},
+		radius: starClasses[class].radius,
+		mass:   starClasses[class].mass,
+		class:  class,
+		sprite: genSun(noise.New(rand.Int63()), class),
+	}
+	parent.attach(&s.body)
+	s.pos = s.at(gameTime)
+
+	objectCount++
+
+	return s
+}
+
+// newBinary creates two stars orbiting their common barycenter, which
+// orbits parent. separation is the distance between the stars. Both stars
+// always are on opposite sides of the barycenter, the heavier one closer
+// to it.
+func newBinary(parent *body, separation float64, a, b starClass) (*star, *star) {
+	ma, mb := starClasses[a].mass, starClasses[b].mass
+	o := orbit{
+		periapsis: rand.Float64() * 2 * math.Pi,
+		phase:     rand.Float64() * 2 * math.Pi,
+		period:    keplerPeriod(separation, ma+mb),
+		dir:       float64(rand.Intn(2)*2 - 1),
+	}
+
+	oa, ob := o, o
+	oa.semiMajor = separation * mb / (ma + mb)
+	ob.semiMajor = separation * ma / (ma + mb)
+	ob.periapsis += math.Pi
+	return newStar(oa, parent, a), newStar(ob, parent, b)
+}
+
+// nearestStar returns the star closest to pos or nil if there are none.
+func nearestStar(pos pixel.Vec) *star {
+	var nearest *star
+	for _, s := range stars {
+		if nearest == nil || s.pos.Sub(pos).Len() < nearest.pos.Sub(pos).Len() {
+			nearest = s
+		}
+	}
+	return nearest
+}
+
+// overlaps reports whether a circle at pos with the given radius touches
+// the star.
+func (s *star) overlaps(pos pixel.Vec, radius float64) bool {
+	return s.pos.Sub(pos).Len() < s.radius+radius
+}
+
+func (s *star) update(dt float64) {
+	s.sprite.animate(gameTime)
+}
+
+func (s *star) draw() {
+	// The corona slowly rotates and pulses.
+	pulse := 1 + 0.05*math.Sin(gameTime*2)
+	s.sprite.corona.Draw(batches.planets, pixel.IM.Rotated(pixel.ZV, gameTime*0.05).Scaled(pixel.ZV, pulse).Moved(s.pos))
+	s.sprite.sprite.Draw(batches.planets, pixel.IM.Moved(s.pos))
+}
+
 type ship struct {
-	orb
+	pos  pixel.Vec
+	dist float64
 	*player
+
+	// The fleet of a ship in flight and its velocity.
+	fleet *fleet
+	vel   pixel.Vec
 }
 
 func newShip(planet *planet, player *player) *ship {
@@ -140,9 +266,6 @@ func newShip(planet *planet, player *player) *ship {
 
 	// TODO remove magic numbers
 	sp.dist = planet.radius * 2
-	sp.anchor = &planet.pos
-	sp.vel = pixel.V(5, 5)
-	sp.dir = 1
 	sp.player = player
 
 	return sp
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/colornames"
+
+	"github.com/dbriemann/gonk/noise"
+)
+
+// spriteManifest describes all sprites written by the sprites command.
+type spriteManifest struct {
+	Seed    int64            `json:"seed"`
+	Version int              `json:"version"`
+	Sprites []manifestSprite `json:"sprites"`
+}
+
+// manifestSprite is a single PNG file written by the sprites command.
+type manifestSprite struct {
+	Name      string                 `json:"name"`
+	File      string                 `json:"file"`
+	Width     int                    `json:"width"`
+	Height    int                    `json:"height"`
+	Generator string                 `json:"generator"`
+	Params    map[string]interface{} `json:"params,omitempty"`
+}
+
+// spriteExporter writes sprites into a directory and records them in the
+// manifest.
+type spriteExporter struct {
+	dir      string
+	manifest spriteManifest
+}
+
+// exportSprites implements the sprites command:
+//
+//	gonk sprites -seed N -out dir
+//
+// It runs the procedural generators without opening a window and writes
+// their output as PNG files plus a manifest.json. The sprite cache is not
+// used, so changes to the generators always show up.
+func exportSprites(args []string) error {
+	flags := flag.NewFlagSet("sprites", flag.ContinueOnError)
+	seed := flags.Int64("seed", 1, "seed of the generated sprites")
+	out := flags.String("out", "sprites", "directory the sprites are written to")
+	radius := flags.Float64("radius", 30, "radius of planets in pixels")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	e := &spriteExporter{
+		dir:      *out,
+		manifest: spriteManifest{Seed: *seed, Version: spriteCacheVersion},
+	}
+	rand.Seed(*seed)
+
+	ship := gradientDisc(16, 0.95, colornames.White)
+	if err := e.write("ship", "gradientDisc", ship, discSize(16), discSize(16), map[string]interface{}{
+		"radius": 16, "density": 0.95,
+	}); err != nil {
+		return err
+	}
+	size := discSize(*radius)
+	if err := e.write("shadow", "genShadow", shadowPixels(*radius), size, size, map[string]interface{}{
+		"radius": *radius,
+	}); err != nil {
+		return err
+	}
+	thickness := 0.15
+	atmoSize := discSize(*radius * (1 + thickness))
+	if err := e.write("atmosphere", "genAtmosphere", atmospherePixels(*radius, thickness), atmoSize, atmoSize, map[string]interface{}{
+		"radius": *radius, "thickness": thickness,
+	}); err != nil {
+		return err
+	}
+
+	for b := biome(0); b < biomeCount; b++ {
+		if err := e.planet(planetSpec{rand.Int63(), *radius, b}); err != nil {
+			return err
+		}
+	}
+	for c := starClass(0); c < starClassCount; c++ {
+		if err := e.star(c, rand.Int63()); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.MarshalIndent(e.manifest, "", "\t")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(e.dir, "manifest.json"), data, 0644)
+}
+
+// planet writes the surface map, the sprite and the rings of a planet.
+func (e *spriteExporter) planet(spec planetSpec) error {
+	name := fileName(spec.biome.String())
+	params := map[string]interface{}{
+		"seed": spec.seed, "radius": spec.radius, "biome": spec.biome.String(),
+	}
+
+	surface := genPlanetSurface(spec)
+	width, height := spec.surfaceSize()
+	pixels := make([]uint8, width*height*4)
+	for i, c := range surface {
+		pixels[i*4] = uint8(c.R * 255)
+		pixels[i*4+1] = uint8(c.G * 255)
+		pixels[i*4+2] = uint8(c.B * 255)
+		pixels[i*4+3] = uint8(c.A * 255)
+	}
+	if err := e.write("surface-"+name, "genPlanetSurface", pixels, width, height, params); err != nil {
+		return err
+	}
+
+	size := discSize(spec.radius)
+	t := projectPlanet(spec, surface)
+	if err := e.write("planet-"+name, "projectPlanet", t.pixels, size, size, params); err != nil {
+		return err
+	}
+
+	ringSeed := rand.Int63()
+	back, front := ringPixels(noise.New(ringSeed), spec.radius, 1.3, 1.8, ringTint(spec.biome))
+	ringSize := discSize(spec.radius * 1.8)
+	ringParams := map[string]interface{}{
+		"seed": ringSeed, "radius": spec.radius, "inner": 1.3, "outer": 1.8, "biome": spec.biome.String(),
+	}
+	if err := e.write("ring-"+name+"-back", "genRing", back, ringSize, ringSize, ringParams); err != nil {
+		return err
+	}
+	return e.write("ring-"+name+"-front", "genRing", front, ringSize, ringSize, ringParams)
+}
+
+// star writes the surface and the corona of a star.
+func (e *spriteExporter) star(class starClass, seed int64) error {
+	name := fileName(class.String())
+	params := starClasses[class]
+	n := noise.New(seed)
+	info := map[string]interface{}{
+		"seed": seed, "radius": params.radius, "class": class.String(),
+	}
+
+	size := discSize(params.radius)
+	pixels := make([]uint8, size*size*4)
+	renderSun(pixels, n, params.radius, starGradient(params.color), 0)
+	if err := e.write("sun-"+name, "genSun", pixels, size, size, info); err != nil {
+		return err
+	}
+
+	coronaSize := discSize(params.radius * 2)
+	return e.write("corona-"+name, "genCorona", coronaPixels(n, params.radius, params.color), coronaSize, coronaSize, info)
+}
+
+// write saves pixels as a PNG file and adds it to the manifest. The pixels
+// are in canvas order, so the first row is the bottom of the image.
+func (e *spriteExporter) write(name, generator string, pixels []uint8, width, height int, params map[string]interface{}) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := pixels[y*width*4 : (y+1)*width*4]
+		copy(img.Pix[(height-1-y)*img.Stride:], row)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	file := name + ".png"
+	if err := ioutil.WriteFile(filepath.Join(e.dir, file), buf.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	e.manifest.Sprites = append(e.manifest.Sprites, manifestSprite{
+		Name:      name,
+		File:      file,
+		Width:     width,
+		Height:    height,
+		Generator: generator,
+		Params:    params,
+	})
+	fmt.Println("wrote", filepath.Join(e.dir, file))
+	return nil
+}
+
+// discSize returns the width and height of a sprite of a disc with the
+// given radius.
+func discSize(radius float64) int {
+	return int(radius*2 + 1)
+}
+
+// fileName turns a display name into a file name.
+func fileName(name string) string {
+	return strings.ReplaceAll(name, " ", "-")
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+const (
+	// interceptHorizon is the longest flight (s) the intercept solver
+	// looks for a solution.
+	interceptHorizon = 300.0
+	// interceptStep is the time step (s) used to find the first possible
+	// intercept before it is refined.
+	interceptStep = 0.5
+	// replanInterval is the time (s) between two course corrections of a
+	// fleet. Avoiding obstacles moves fleets off their intercept course.
+	replanInterval = 0.5
+	// missTolerance is the time (s) a fleet may be late before it gives
+	// up flying curves and turns straight towards the target.
+	missTolerance = 5.0
+)
+
+// fleet is a group of ships flying from one planet to another.
+type fleet struct {
+	*player
+	pos       pixel.Vec
+	vel       pixel.Vec
+	target    *planet
+	ships     []*ship
+	formation formation
+	// aim is the point where the fleet meets the target on its
+	// intercept course.
+	aim pixel.Vec
+	// homing fleets fly towards the current position of the target
+	// because there was no intercept course.
+	homing bool
+	// replan is the time until the next course correction.
+	replan float64
+	// deadline is the game time by which the fleet should have arrived.
+	deadline float64
+}
+
+// intercept computes the course of a fleet at from with the given speed to
+// meet target. It returns the direction to fly in and the flight time. The
+// target's position is known for every point in time, so this also works
+// for satellites of moving planets. There is no closed-form solution for
+// elliptical orbits, so the first time the fleet can be at the target's
+// position is searched numerically. ok is false if there is no such time
+// within interceptHorizon, e.g. because the target is too fast.
+func intercept(from pixel.Vec, speed float64, target *planet) (heading pixel.Vec, eta float64, ok bool) {
+	// miss is negative once the fleet could have reached the target.
+	miss := func(t float64) float64 {
+		return target.at(gameTime+t).Sub(from).Len() - speed*t
+	}
+
+	if miss(0) <= 0 {
+		return pixel.ZV, 0, true
+	}
+	lo, hi := 0.0, -1.0
+	for t := interceptStep; t <= interceptHorizon; t += interceptStep {
+		if miss(t) <= 0 {
+			lo, hi = t-interceptStep, t
+			break
+		}
+	}
+	if hi < 0 {
+		return pixel.ZV, 0, false
+	}
+	for i := 0; i < 20; i++ {
+		mid := (lo + hi) / 2
+		if miss(mid) <= 0 {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	return target.at(gameTime + hi).Sub(from).Unit(), hi, true
+}
+
+// sendFleet sends half of the ships stationed at source to target.
+func sendFleet(source, target *planet) {
+	n := len(source.ships) / 2
+	if n == 0 {
+		return
+	}
+	f := &fleet{
+		player:    source.player,
+		pos:       source.pos,
+		target:    target,
+		ships:     append([]*ship(nil), source.ships[:n]...),
+		formation: fleetFormation,
+	}
+	source.ships = source.ships[n:]
+	// The ships start where they were stationed.
+	for _, s := range f.ships {
+		s.fleet = f
+		s.vel = pixel.ZV
+	}
+	fleets = append(fleets, f)
+}
+
+// plan computes the intercept course from the current position.
+func (f *fleet) plan() {
+	_, eta, ok := intercept(f.pos, fleetSpeed, f.target)
+	f.aim = f.target.at(gameTime + eta)
+	f.homing = !ok
+	if f.deadline == 0 {
+		f.deadline = gameTime + eta*2 + missTolerance
+	}
+}
+
+// update steers the fleet towards its target and around obstacles and
+// reports whether it has arrived. Arrived ships are stationed at the
+// target.
+func (f *fleet) update(dt float64) bool {
+	f.replan -= dt
+	if f.replan <= 0 {
+		f.plan()
+		f.replan = replanInterval
+	}
+
+	// A target within the turning circle can't be reached with a limited
+	// turn rate, so close to the target and when the fleet is late it
+	// turns straight towards it.
+	aim := f.aim
+	maxTurn := fleetTurnRate * dt
+	turnRadius := fleetSpeed / fleetTurnRate
+	if f.target.pos.Sub(f.pos).Len() < turnRadius+f.target.radius || gameTime > f.deadline {
+		aim = f.target.pos
+		maxTurn = math.Pi
+	} else if f.homing {
+		aim = f.target.pos
+	}
+	desired := aim.Sub(f.pos).Unit()
+	if push := avoid(f.pos, f.vel, f.target); push != pixel.ZV {
+		desired = desired.Add(push.Scaled(avoidWeight)).Unit()
+	}
+	f.vel = steer(f.vel, desired.Scaled(fleetSpeed), maxTurn)
+	f.pos = f.pos.Add(f.vel.Scaled(dt))
+
+	// The radius absorbs the error of the time step.
+	if f.target.pos.Sub(f.pos).Len() > math.Max(f.target.radius, fleetSpeed*dt) {
+		return false
+	}
+	for _, s := range f.ships {
+		s.fleet = nil
+		s.dist = f.target.radius * 2
+	}
+	f.target.ships = append(f.target.ships, f.ships...)
+	f.ships = nil
+	return true
+}
+
+func (f *fleet) draw() {
+	for _, s := range f.ships {
+		s.draw()
+	}
+}
+
+// updateFleets updates all fleets and removes the ones that arrived.
+func updateFleets(dt float64) {
+	if dt == 0 {
+		return
+	}
+	flying := fleets[:0]
+	for _, f := range fleets {
+		if !f.update(dt) {
+			f.flock(dt, shipGrid)
+			flying = append(flying, f)
+		}
+	}
+	for i := len(flying); i < len(fleets); i++ {
+		fleets[i] = nil
+	}
+	fleets = flying
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// formation is the arrangement of the ships of a fleet in flight.
+type formation int
+
+const (
+	// formationSwarm only loosely holds places, mostly the ships flock.
+	formationSwarm formation = iota
+	formationWedge
+	formationColumn
+	// formationSphere packs the ships into a disc.
+	formationSphere
+	formationCount
+)
+
+func (f formation) String() string {
+	return [formationCount]string{
+		formationSwarm:  "swarm",
+		formationWedge:  "wedge",
+		formationColumn: "column",
+		formationSphere: "sphere",
+	}[f]
+}
+
+const (
+	// formationSpacing is the distance between neighboring places in a
+	// formation.
+	formationSpacing = 6.0
+	// Ships only react to ships within neighborRadius and move away from
+	// ships within separationRadius.
+	neighborRadius   = 12.0
+	separationRadius = 5.0
+	// maxNeighbors limits how many of the nearest ships a ship reacts to.
+	// They are picked from at most maxCandidates ships of any fleet. In a
+	// dense swarm this keeps the cost per ship constant.
+	maxNeighbors  = 8
+	maxCandidates = 4 * maxNeighbors
+
+	// Weights of the flocking rules. They are accelerations in px/s².
+	separationWeight = 400.0
+	alignmentWeight  = 1.0
+	cohesionWeight   = 1.5
+	// placeWeight is how quickly ships correct towards their place in
+	// the formation.
+	placeWeight = 4.0
+
+	maxShipAccel = 300.0
+	// Ships have to be faster than their fleet to catch up.
+	maxShipSpeed = 1.5
+)
+
+// place returns the position of the i-th of n ships relative to the fleet.
+// X points in the direction of flight.
+func (f formation) place(i, n int) pixel.Vec {
+	switch f {
+	case formationWedge:
+		// The leader is at the tip, the others follow in rows of two.
+		row := float64((i + 1) / 2)
+		side := float64(i%2*2 - 1)
+		return pixel.V(-row, side*row).Scaled(formationSpacing)
+	case formationColumn:
+		return pixel.V(float64(n-1)/2-float64(i), 0).Scaled(formationSpacing)
+	case formationSwarm, formationSphere:
+		// A sunflower pattern fills a disc evenly.
+		golden := math.Pi * (3 - math.Sqrt(5))
+		r := math.Sqrt(float64(i)) * formationSpacing * 0.6
+		return pixel.V(r, 0).Rotated(float64(i) * golden)
+	}
+	return pixel.ZV
+}
+
+// pull returns how quickly ships correct towards their place.
+func (f formation) pull() float64 {
+	if f == formationSwarm {
+		return placeWeight / 4
+	}
+	return placeWeight
+}
+
+// neighbor is a ship near another ship.
+type neighbor struct {
+	ship *ship
+	dist float64
+}
+
+// nearestShips returns the up to maxNeighbors ships within neighborRadius
+// of s, sorted by distance. The result is stored in buf. Only the first
+// maxCandidates ships found are considered, so in a very dense swarm a
+// closer ship can be missed.
+func nearestShips(grid *spatialGrid, s *ship, buf *[maxNeighbors]neighbor) []neighbor {
+	n, candidates := 0, 0
+	grid.near(s.pos, neighborRadius, func(e spatialEntry) bool {
+		if e.ship == s {
+			return true
+		}
+		candidates++
+		dist := e.pos.Sub(s.pos).Len()
+		if n == maxNeighbors {
+			if dist >= buf[n-1].dist {
+				return candidates < maxCandidates
+			}
+			n--
+		}
+		// Insertion sort, the farthest ship drops out once buf is full.
+		i := n
+		for ; i > 0 && buf[i-1].dist > dist; i-- {
+			buf[i] = buf[i-1]
+		}
+		buf[i] = neighbor{e.ship, dist}
+		n++
+		return candidates < maxCandidates
+	})
+	return buf[:n]
+}
+
+// flock moves the ships of the fleet with the boids rules separation,
+// alignment and cohesion, while they keep up with the fleet and hold
+// their place in its formation. grid contains all ships.
+func (f *fleet) flock(dt float64, grid *spatialGrid) {
+	angle := f.vel.Angle()
+	var buf [maxNeighbors]neighbor
+
+	for i, s := range f.ships {
+		place := f.pos.Add(f.formation.place(i, len(f.ships)).Rotated(angle))
+
+		var separation, alignment, center pixel.Vec
+		neighbors := 0
+		for _, n := range nearestShips(grid, s, &buf) {
+			o := n.ship
+			if n.dist < separationRadius {
+				d := s.pos.Sub(o.pos)
+				if n.dist == 0 {
+					d = pixel.V(1, 0).Rotated(float64(i))
+				}
+				separation = separation.Add(d.Unit().Scaled(1 - n.dist/separationRadius))
+			}
+			// Only ships of the same fleet flock together.
+			if o.fleet == f {
+				alignment = alignment.Add(o.vel)
+				center = center.Add(o.pos)
+				neighbors++
+			}
+		}
+
+		// Keep up with the fleet and move to the own place.
+		pull := f.formation.pull()
+		desired := f.vel.Add(place.Sub(s.pos).Scaled(pull))
+		accel := desired.Sub(s.vel).Scaled(pull)
+		accel = accel.Add(separation.Scaled(separationWeight))
+		if neighbors > 0 {
+			alignment = alignment.Scaled(1 / float64(neighbors))
+			center = center.Scaled(1 / float64(neighbors))
+			accel = accel.Add(alignment.Sub(s.vel).Scaled(alignmentWeight))
+			accel = accel.Add(center.Sub(s.pos).Scaled(cohesionWeight))
+		}
+		if accel.Len() > maxShipAccel {
+			accel = accel.Unit().Scaled(maxShipAccel)
+		}
+
+		s.vel = s.vel.Add(accel.Scaled(dt))
+		if s.vel.Len() > fleetSpeed*maxShipSpeed {
+			s.vel = s.vel.Unit().Scaled(fleetSpeed * maxShipSpeed)
+		}
+		s.pos = s.pos.Add(s.vel.Scaled(dt))
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// Font sizes in points at a UI scale of 1.
+const (
+	fontSizeSmall  = 12
+	fontSizeNormal = 16
+	fontSizeLarge  = 28
+)
+
+// loadFont parses the TTF or OTF file at path. If path is empty the
+// bundled Go Regular font is returned.
+func loadFont(path string) (*opentype.Font, error) {
+	if path == "" {
+		return opentype.Parse(goregular.TTF)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return opentype.Parse(data)
+}
+
+// newAtlas renders all ASCII glyphs of f at the given size into an atlas.
+func newAtlas(f *opentype.Font, size float64) *text.Atlas {
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		// NewFace only fails for invalid options.
+		panic(err)
+	}
+	defer face.Close()
+	return text.NewAtlas(face, text.ASCII)
+}
+
+// initFonts loads the font at fontPath and creates the atlases for the
+// current UI scale. A font that can't be loaded is reported and the
+// bundled font is used instead.
+func initFonts() {
+	f, err := loadFont(fontPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not load font %s: %v\n", fontPath, err)
+		f, _ = loadFont("")
+	}
+	uiFont = f
+	setUIScale(uiScale)
+}
+
+// setUIScale rebuilds all font atlases for the given scale and adjusts
+// the sizes that depend on it. Texts and menus have to be recreated
+// afterwards to pick up the new atlases.
+func setUIScale(scale float64) {
+	uiScale = scale
+	uiPadding = 8 * scale
+	fonts.small = newAtlas(uiFont, fontSizeSmall*scale)
+	fonts.normal = newAtlas(uiFont, fontSizeNormal*scale)
+	fonts.large = newAtlas(uiFont, fontSizeLarge*scale)
+}
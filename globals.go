@@ -3,11 +3,11 @@ package main
 import (
 	"time"
 
-	opensimplex "github.com/ojrac/opensimplex-go"
-
 	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/pixelgl"
 	"github.com/faiface/pixel/text"
+	"golang.org/x/image/font/opentype"
 )
 
 var (
@@ -16,42 +16,146 @@ var (
 
 	frameTick *time.Ticker
 	fps       float64
+	fpsLimit  int
+
+	// The simulation speed is speeds[speedIndex]. It scales the dt
+	// passed to update. A paused game does not update at all.
+	paused     bool
+	speeds     = []float64{0.25, 0.5, 1, 2, 4, 8}
+	speedIndex = 2
 
+	// The window size in windowed mode or the video mode in fullscreen.
 	screenWidth  = 1200
 	screenHeight = 800
 	title        = "Gonk"
+	display      = displayWindowed
+	monitorIndex int
+	vsync        bool
 
 	worldCanvas *pixelgl.Canvas
 
+	// The active screen and the main menu. gameRunning is set as soon as
+	// a game has been started and stays set while the menu is shown on top.
+	state       gameState
+	menu        *ui
+	menuScreen  func()
+	gameRunning bool
+
+	keys = defaultKeys
+
+	// Use player colors that can be told apart with color vision deficiencies.
+	colorblind bool
+
+	// Volumes range from 0 to 1. There is no audio yet, they are only
+	// kept in the settings.
+	musicVolume   = 1.0
+	effectsVolume = 1.0
+
+	// settingsDraft holds the values edited in the settings screen until
+	// they are applied.
+	settingsDraft settings
+
 	// Our 'camera' targets (0,0) which will be the center of the screen.
-	camPos = pixel.ZV
-	cam    pixel.Matrix
+	camPos   = pixel.ZV
+	cam      pixel.Matrix
+	panSpeed = 300.0
+
+	// seed determines everything that is generated for a game. A game
+	// can be replayed by starting it with the same seed, which also
+	// reuses the cached planet sprites. firstSeed is the seed of the
+	// first game if set with the -seed flag.
+	seed      int64
+	firstSeed int64
+	bg        *background
 
+	// system is the root of the orbital hierarchy. Stars and planets
+	// orbit it, satellites orbit their planets. A system has up to two
+	// stars.
+	system  *body
+	stars   []*star
 	planets []*planet
 	players []player
 
+	// All sprites live in the atlas. atlasStatic marks the end of the
+	// sprites that do not depend on the map.
 	sprites struct {
-		// TODO planets -> one canvas -> spritesheet -> batch
-		planets []*pixelgl.Canvas
-		sun     *pixelgl.Canvas
-		ship    *pixelgl.Canvas
+		atlas       *spriteAtlas
+		atlasStatic atlasMark
+		ship        *pixel.Sprite
+		// Overlays for lighting planets.
+		shadow     *pixel.Sprite
+		atmosphere *pixel.Sprite
 	}
 
+	// overlay draws orbit paths and other markers in the world.
+	overlay *imdraw.IMDraw
+
+	// Batches draw from the sprite atlas. Planets include the sun.
 	batches struct {
-		ships *pixel.Batch
+		planets *pixel.Batch
+		ships   *pixel.Batch
 	}
 
-	origin         = &pixel.Vec{X: 0, Y: 0}
 	planetSizes    = []int{9, 10, 11}
 	satelliteSizes = []int{5, 6, 7}
 	recycledShips  = []*ship{}
 
 	productionFactor = 0.1
 
-	frames      uint64
-	fpsText     *text.Text
-	objectsText *text.Text
-	objectCount uint64 = 1 // Includes the sun at the start.
+	// maxEccentricity is the largest eccentricity of a planet's orbit.
+	// Satellites get half of it.
+	maxEccentricity = 0.1
+
+	// With keplerOrbits the orbital periods follow Kepler's third law
+	// from the mass of the anchor. Otherwise the speeds are random.
+	// Masses are in units of a yellow star.
+	keplerOrbits = true
+	// showOrbits draws the orbit paths of all planets.
+	showOrbits = true
+	// fleetSpeed is the speed of fleets in px/s.
+	fleetSpeed = 60.0
+	// aimSource is the planet the player aims a fleet from or nil.
+	aimSource *planet
+	fleets    []*fleet
+	// fleetFormation is the formation of newly sent fleets.
+	fleetFormation formation
+	// The spatial index of the world is rebuilt every tick. Ships are
+	// kept apart from stars and planets, so the large radii of stars do
+	// not slow down the many queries for ships.
+	bodyGrid = newSpatialGrid(64)
+	shipGrid = newSpatialGrid(neighborRadius)
+	// maxBodySpeed is the speed of the fastest star or planet in px/s.
+	maxBodySpeed float64
+
+	// gravity is the gravitational constant in px³/s² per unit of mass.
+	gravity = 8000.0
+	// planetDensity converts the cubed radius of a planet to its mass.
+	planetDensity = 0.0002
+
+	// gameTime is the time in seconds the current game has been running,
+	// scaled by the simulation speed.
+	gameTime float64
 
-	noise *opensimplex.Noise
+	// Planets slowly rotate around their axis (rad per second).
+	rotatePlanets       = true
+	planetRotationSpeed = 0.1
+
+	frames        uint64
+	fpsText       *text.Text
+	objectsText   *text.Text
+	speedText     *text.Text
+	formationText *text.Text
+	seedText      *text.Text
+	objectCount   uint64 // Includes the stars.
+
+	// The font used by HUD and menus. An empty fontPath selects the
+	// bundled default font. All font sizes are multiplied by uiScale.
+	fontPath string
+	uiFont   *opentype.Font
+	uiScale  = 1.0
+	fonts    struct {
+		small  *text.Atlas
+		normal *text.Atlas
+		large  *text.Atlas
+	}
 )
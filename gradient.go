@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// easing shapes the transition between two stops of a gradient. It maps
+// the position between them (0-1) to the share of the second color.
+type easing int
+
+const (
+	easeLinear easing = iota
+	easeQuadratic
+	easeSmoothstep
+)
+
+// apply returns the eased value of t.
+func (e easing) apply(t float64) float64 {
+	switch e {
+	case easeQuadratic:
+		return t * t
+	case easeSmoothstep:
+		return smoothstep(0, 1, t)
+	}
+	return t
+}
+
+// blendMode determines how a layer is combined with the pixels below it.
+// All colors are alpha-premultiplied.
+type blendMode int
+
+const (
+	// blendNormal draws the layer over the pixels below.
+	blendNormal blendMode = iota
+	// blendAdd adds the layer, which brightens. Good for glows.
+	blendAdd
+	// blendMultiply darkens the pixels below by the layer.
+	blendMultiply
+	// blendScreen brightens like blendAdd but never overexposes.
+	blendScreen
+)
+
+// blend combines the source color s with the destination color d.
+func (m blendMode) blend(d, s pixel.RGBA) pixel.RGBA {
+	switch m {
+	case blendAdd:
+		return pixel.RGBA{
+			R: math.Min(1, d.R+s.R),
+			G: math.Min(1, d.G+s.G),
+			B: math.Min(1, d.B+s.B),
+			A: math.Min(1, d.A+s.A),
+		}
+	case blendMultiply:
+		return pixel.RGBA{
+			R: s.R*d.R + s.R*(1-d.A) + d.R*(1-s.A),
+			G: s.G*d.G + s.G*(1-d.A) + d.G*(1-s.A),
+			B: s.B*d.B + s.B*(1-d.A) + d.B*(1-s.A),
+			A: s.A + d.A*(1-s.A),
+		}
+	case blendScreen:
+		return pixel.RGBA{
+			R: s.R + d.R - s.R*d.R,
+			G: s.G + d.G - s.G*d.G,
+			B: s.B + d.B - s.B*d.B,
+			A: s.A + d.A - s.A*d.A,
+		}
+	}
+	return s.Add(d.Scaled(1 - s.A))
+}
+
+// radialGradient is a disc whose color depends on the distance to its
+// center. The stops are positioned between inner and the radius, so the
+// stop at 1 is the color of the edge. Everything inside of inner has the
+// color of the first stop, everything outside the radius is untouched.
+type radialGradient struct {
+	radius float64
+	inner  float64
+	stops  gradient
+	ease   easing
+	blend  blendMode
+	// stretch optionally scales the radius depending on the direction
+	// from the center, which makes the edge streaky. It must not be
+	// larger than 1.
+	stretch func(dir pixel.Vec) float64
+	// intensity optionally scales the color depending on the direction
+	// from the center, e.g. to light one side of the disc.
+	intensity func(dir pixel.Vec) float64
+}
+
+// draw blends the gradient into the center of a square pixel buffer of
+// the given size.
+func (g radialGradient) draw(pixels []uint8, size int) {
+	center := float64(size-1) / 2
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			v := pixel.V(float64(x), float64(y)).Sub(pixel.V(center, center))
+			dist, dir := v.Len(), v.Unit()
+			radius := g.radius
+			if g.stretch != nil {
+				radius *= g.stretch(dir)
+			}
+			if dist > radius {
+				continue
+			}
+			col := g.stops.eased(math.Max(0, dist-g.inner)/(radius-g.inner), g.ease)
+			if g.intensity != nil {
+				col = col.Scaled(g.intensity(dir))
+			}
+
+			index := y*size*4 + x*4
+			dst := pixel.RGBA{
+				R: float64(pixels[index]) / 255,
+				G: float64(pixels[index+1]) / 255,
+				B: float64(pixels[index+2]) / 255,
+				A: float64(pixels[index+3]) / 255,
+			}
+			col = g.blend.blend(dst, col)
+			pixels[index] = uint8(col.R * 255)
+			pixels[index+1] = uint8(col.G * 255)
+			pixels[index+2] = uint8(col.B * 255)
+			pixels[index+3] = uint8(col.A * 255)
+		}
+	}
+}
+
+// radialGradientPixels draws the layers on top of each other in the given
+// order. The result is large enough for the layer with the largest radius.
+func radialGradientPixels(layers ...radialGradient) (pixels []uint8, size int) {
+	radius := 0.0
+	for _, l := range layers {
+		radius = math.Max(radius, l.radius)
+	}
+	size = int(radius*2 + 1)
+	pixels = make([]uint8, size*size*4)
+
+	for _, l := range layers {
+		l.draw(pixels, size)
+	}
+
+	return
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// rgbaNear reports whether all components of a and b differ by less than 1e-9.
+func rgbaNear(a, b pixel.RGBA) bool {
+	const eps = 1e-9
+	return math.Abs(a.R-b.R) < eps && math.Abs(a.G-b.G) < eps &&
+		math.Abs(a.B-b.B) < eps && math.Abs(a.A-b.A) < eps
+}
+
+func TestBlend(t *testing.T) {
+	gray := pixel.RGBA{R: 0.5, G: 0.5, B: 0.5, A: 1}
+	// Half transparent orange, alpha-premultiplied.
+	orange := pixel.RGBA{R: 0.4, G: 0.2, B: 0, A: 0.5}
+	white := pixel.RGBA{R: 1, G: 1, B: 1, A: 1}
+	tests := []struct {
+		mode blendMode
+		d, s pixel.RGBA
+		want pixel.RGBA
+	}{
+		{blendNormal, gray, orange, pixel.RGBA{R: 0.65, G: 0.45, B: 0.25, A: 1}},
+		{blendAdd, gray, orange, pixel.RGBA{R: 0.9, G: 0.7, B: 0.5, A: 1}},
+		{blendMultiply, gray, orange, pixel.RGBA{R: 0.45, G: 0.35, B: 0.25, A: 1}},
+		{blendScreen, gray, orange, pixel.RGBA{R: 0.7, G: 0.6, B: 0.5, A: 1}},
+
+		// Nothing below the layer: every mode draws the layer unchanged.
+		{blendNormal, pixel.RGBA{}, orange, orange},
+		{blendAdd, pixel.RGBA{}, orange, orange},
+		{blendMultiply, pixel.RGBA{}, orange, orange},
+		{blendScreen, pixel.RGBA{}, orange, orange},
+
+		// Adding is clamped, screening never exceeds 1 anyway.
+		{blendAdd, white, white, white},
+		{blendScreen, white, white, white},
+		// Multiplying by white keeps the pixels below.
+		{blendMultiply, gray, white, gray},
+	}
+	for _, test := range tests {
+		if got := test.mode.blend(test.d, test.s); !rgbaNear(got, test.want) {
+			t.Errorf("mode %d: blend(%v, %v) = %v, want %v", test.mode, test.d, test.s, got, test.want)
+		}
+	}
+}
+
+func TestEased(t *testing.T) {
+	black := pixel.RGBA{A: 1}
+	white := pixel.RGBA{R: 1, G: 1, B: 1, A: 1}
+	gray := func(v float64) pixel.RGBA {
+		return pixel.RGBA{R: v, G: v, B: v, A: 1}
+	}
+	g := gradient{{0.2, black}, {0.6, white}, {1, black}}
+	tests := []struct {
+		ease easing
+		t    float64
+		want pixel.RGBA
+	}{
+		// Outside of the stops and on them the easing does not matter.
+		{easeLinear, 0, black},
+		{easeQuadratic, 0.2, black},
+		{easeSmoothstep, 0.6, white},
+		{easeQuadratic, 1.5, black},
+
+		{easeLinear, 0.3, gray(0.25)},
+		{easeQuadratic, 0.3, gray(0.0625)},
+		{easeSmoothstep, 0.3, gray(0.15625)},
+		{easeSmoothstep, 0.4, gray(0.5)},
+		// The second transition goes back to black.
+		{easeLinear, 0.7, gray(0.75)},
+		{easeQuadratic, 0.7, gray(0.9375)},
+		{easeSmoothstep, 0.7, gray(0.84375)},
+	}
+	for _, test := range tests {
+		if got := g.eased(test.t, test.ease); !rgbaNear(got, test.want) {
+			t.Errorf("easing %d: eased(%v) = %v, want %v", test.ease, test.t, got, test.want)
+		}
+	}
+	if got := g.at(0.3); !rgbaNear(got, gray(0.25)) {
+		t.Errorf("at(0.3) = %v, want %v", got, gray(0.25))
+	}
+}
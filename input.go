@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// action is something the player can bind a key to.
+type action int
+
+const (
+	actionPause action = iota
+	actionSpeedUp
+	actionSpeedDown
+	actionFullscreen
+	actionPanLeft
+	actionPanRight
+	actionPanUp
+	actionPanDown
+	actionToggleOrbits
+	actionFormation
+	actionCount
+)
+
+// actionNames are used for displaying and persisting key bindings.
+var actionNames = [actionCount]string{
+	actionPause:        "Pause",
+	actionSpeedUp:      "Speed up",
+	actionSpeedDown:    "Slow down",
+	actionFullscreen:   "Fullscreen",
+	actionPanLeft:      "Pan left",
+	actionPanRight:     "Pan right",
+	actionPanUp:        "Pan up",
+	actionPanDown:      "Pan down",
+	actionToggleOrbits: "Toggle orbits",
+	actionFormation:    "Fleet formation",
+}
+
+// defaultKeys are the key bindings used if the settings do not override them.
+var defaultKeys = [actionCount]pixelgl.Button{
+	actionPause:        pixelgl.KeySpace,
+	actionSpeedUp:      pixelgl.KeyEqual,
+	actionSpeedDown:    pixelgl.KeyMinus,
+	actionFullscreen:   pixelgl.KeyF11,
+	actionPanLeft:      pixelgl.KeyLeft,
+	actionPanRight:     pixelgl.KeyRight,
+	actionPanUp:        pixelgl.KeyUp,
+	actionPanDown:      pixelgl.KeyDown,
+	actionToggleOrbits: pixelgl.KeyO,
+	actionFormation:    pixelgl.KeyF,
+}
+
+// altKeys are additional fixed bindings that work next to the configurable
+// ones.
+var altKeys = map[action]pixelgl.Button{
+	actionPause:     pixelgl.KeyPause,
+	actionSpeedUp:   pixelgl.KeyKPAdd,
+	actionSpeedDown: pixelgl.KeyKPSubtract,
+}
+
+// buttonByName returns the key with the given name as returned by
+// pixelgl.Button.String.
+func buttonByName(name string) (pixelgl.Button, bool) {
+	for b := pixelgl.KeySpace; b <= pixelgl.KeyLast; b++ {
+		if b.String() == name {
+			return b, true
+		}
+	}
+	return pixelgl.KeyUnknown, false
+}
+
+// triggered reports whether the key bound to a or its alternative key was
+// pressed this frame.
+func triggered(a action) bool {
+	if alt, ok := altKeys[a]; ok && window.JustPressed(alt) {
+		return true
+	}
+	return window.JustPressed(keys[a])
+}
+
+// handleInput processes all keyboard and mouse input for the current frame.
+// dt is the real time since the last frame.
+func handleInput(dt float64) {
+	if triggered(actionFullscreen) && !(state == stateMenu && menu.capturing()) {
+		toggleFullscreen()
+		return
+	}
+
+	if state == stateMenu {
+		menu.update(window)
+		return
+	}
+
+	if window.JustPressed(pixelgl.KeyEscape) {
+		showMainMenu()
+		return
+	}
+	if triggered(actionPause) {
+		paused = !paused
+	}
+	if triggered(actionSpeedUp) {
+		requestSpeed(speedIndex + 1)
+	}
+	if triggered(actionSpeedDown) {
+		requestSpeed(speedIndex - 1)
+	}
+	if triggered(actionToggleOrbits) {
+		showOrbits = !showOrbits
+	}
+	if triggered(actionFormation) {
+		fleetFormation = (fleetFormation + 1) % formationCount
+	}
+
+	// Clicking a planet aims from it, clicking another planet while
+	// aiming sends a fleet there. Clicking anywhere else stops aiming.
+	if window.JustPressed(pixelgl.MouseButtonLeft) {
+		clicked := planetAt(screenToWorld(window.MousePosition()))
+		if aimSource != nil && clicked != nil && clicked != aimSource {
+			sendFleet(aimSource, clicked)
+			clicked = nil
+		}
+		aimSource = clicked
+	}
+	if window.JustPressed(pixelgl.MouseButtonRight) {
+		aimSource = nil
+	}
+
+	var pan pixel.Vec
+	if window.Pressed(keys[actionPanLeft]) {
+		pan.X--
+	}
+	if window.Pressed(keys[actionPanRight]) {
+		pan.X++
+	}
+	if window.Pressed(keys[actionPanUp]) {
+		pan.Y++
+	}
+	if window.Pressed(keys[actionPanDown]) {
+		pan.Y--
+	}
+	if pan != pixel.ZV {
+		moveCamera(pan.Scaled(panSpeed * dt))
+	}
+}
+
+// moveCamera moves the camera by delta in world coordinates.
+func moveCamera(delta pixel.Vec) {
+	camPos = camPos.Add(delta)
+	cam = pixel.IM.Moved(worldCanvas.Bounds().Center().Sub(camPos))
+	worldCanvas.SetMatrix(cam)
+}
+
+// requestSpeed asks to change the simulation speed to speeds[index].
+// Out of range requests are ignored. There is no multiplayer yet so every
+// request is granted locally. Once there is, this is the place where the
+// host has to approve the change.
+func requestSpeed(index int) {
+	if index < 0 || index >= len(speeds) {
+		return
+	}
+	speedIndex = index
+}
+
+// simSpeed returns the multiplier that is applied to dt before updating
+// the game.
+func simSpeed() float64 {
+	return speeds[speedIndex]
+}
+
+// toggleFullscreen switches between windowed and fullscreen mode.
+func toggleFullscreen() {
+	mode := displayFullscreen
+	if display != displayWindowed {
+		mode = displayWindowed
+	}
+	if err := setDisplay(mode, monitorIndex, screenWidth, screenHeight, vsync); err != nil {
+		fmt.Fprintln(os.Stderr, "could not switch display mode:", err)
+	}
+}
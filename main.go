@@ -1,35 +1,66 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"image/color"
 	"math"
 	"math/rand"
+	"os"
 	"time"
 
 	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
 	"github.com/faiface/pixel/pixelgl"
 	"github.com/faiface/pixel/text"
 	"golang.org/x/image/colornames"
 )
 
-func initScreen() {
+func initScreen() error {
 	primaryMonitor = pixelgl.PrimaryMonitor()
-	cfg := pixelgl.WindowConfig{
-		Title:   title,
-		Bounds:  pixel.R(0, 0, float64(screenWidth), float64(screenHeight)),
-		Monitor: nil,
+	if err := createWindow(); err != nil {
+		return err
 	}
-	win, err := pixelgl.NewWindow(cfg)
-	if err != nil {
-		panic(err)
-	}
-
-	window = win
-	worldCanvas = pixelgl.NewCanvas(win.Bounds())
+	worldCanvas = pixelgl.NewCanvas(window.Bounds())
+	overlay = imdraw.New(nil)
 
 	// Set the camera to look at camPos.
 	cam = pixel.IM.Moved(worldCanvas.Bounds().Center().Sub(camPos))
 	worldCanvas.SetMatrix(cam)
+	return nil
+}
+
+// Player colors by player id. The first one belongs to the pseudo player.
+var (
+	playerColors = []color.Color{
+		colornames.Antiquewhite,
+		colornames.Skyblue,
+		colornames.Tomato,
+		colornames.Limegreen,
+		colornames.Gold,
+		colornames.Orchid,
+		colornames.Orange,
+	}
+	// The Okabe-Ito palette.
+	colorblindColors = []color.Color{
+		colornames.Antiquewhite,
+		color.RGBA{86, 180, 233, 255},
+		color.RGBA{230, 159, 0, 255},
+		color.RGBA{0, 158, 115, 255},
+		color.RGBA{240, 228, 66, 255},
+		color.RGBA{204, 121, 167, 255},
+		color.RGBA{213, 94, 0, 255},
+	}
+)
+
+// playerColor returns the color of the player with the given id from the
+// active palette.
+func playerColor(id int) color.Color {
+	palette := playerColors
+	if colorblind {
+		palette = colorblindColors
+	}
+	return palette[id%len(palette)]
 }
 
 func initPlayers(playerName string, ais int) {
@@ -39,13 +70,13 @@ func initPlayers(playerName string, ais int) {
 			id:    0,
 			name:  "not occupied",
 			ai:    false,
-			color: colornames.Antiquewhite,
+			color: playerColor(0),
 		},
 		player{
 			id:    1,
 			name:  playerName,
 			ai:    false,
-			color: colornames.Skyblue,
+			color: playerColor(1),
 		},
 	}
 }
@@ -70,30 +101,87 @@ func initPlayers(playerName string, ais int) {
 // 	return
 // }
 
-func genSprites(planets int) {
-	for i := 0; i < planets; i++ {
-		sprite := genPlanet(30)
-		sprites.planets = append(sprites.planets, sprite)
-	}
-	sprites.sun = genGradientDisc(30, 0.6, colornames.Gold)
-	sprites.ship = genGradientDisc(16, 0.95, colornames.White)
-	batches.ships = pixel.NewBatch(&pixel.TrianglesData{}, sprites.ship)
+// genSprites generates all sprites that do not depend on the map. Planet
+// textures are generated with the solar system.
+func genSprites() {
+	sprites.atlas = newSpriteAtlas(atlasSize)
+	sprites.shadow = sprites.atlas.addCanvas(genShadow(30))
+	sprites.atmosphere = sprites.atlas.addCanvas(genAtmosphere(30, 0.15))
+	sprites.ship = sprites.atlas.addCanvas(genGradientDisc(16, 0.95, colornames.White))
+	sprites.atlasStatic = sprites.atlas.mark()
+
+	batches.planets = pixel.NewBatch(&pixel.TrianglesData{}, sprites.atlas.canvas)
+	batches.ships = pixel.NewBatch(&pixel.TrianglesData{}, sprites.atlas.canvas)
 }
 
+// initSolarSystem generates the stars and planets. The layout of the stars
+// is chosen randomly, so it follows from the seed.
 func initSolarSystem(planetAmount, maxSatellites, minDist, maxDist int) {
+	system = &body{}
+	stars = nil
+	// Every planet gets its own texture. They are generated all at once
+	// after the layout is done, in the same order as the planets.
+	var specs []planetSpec
+
+	switch randomLayout() {
+	case layoutEmpty:
+		// Without stars the planets orbit their common barycenter.
+		mass := float64(planetAmount) * math.Pow(float64(planetSizes[1]), 3) * planetDensity
+		specs = addPlanets(system, mass, planetAmount, maxSatellites, minDist, maxDist)
+	case layoutSingle:
+		s := newStar(orbit{}, system, randomStarClass())
+		stars = append(stars, s)
+		specs = addPlanets(system, s.mass, planetAmount, maxSatellites, minDist, maxDist)
+	case layoutCloseBinary:
+		a, b := randomStarClass(), randomStarClass()
+		separation := (starClasses[a].radius + starClasses[b].radius) * 1.3
+		sa, sb := newBinary(system, separation, a, b)
+		stars = append(stars, sa, sb)
+		// Orbits around a pair are only stable at a distance.
+		if d := int(2 * separation); d > minDist {
+			minDist = d
+		}
+		specs = addPlanets(system, sa.mass+sb.mass, planetAmount, maxSatellites, minDist, maxDist)
+	case layoutWideBinary:
+		sa, sb := newBinary(system, float64(maxDist), randomStarClass(), randomStarClass())
+		stars = append(stars, sa, sb)
+		// Each star has its own smaller system. Its planets must keep clear
+		// of the star itself.
+		largest := planetSizes[len(planetSizes)-1]
+		for _, s := range stars {
+			near := minDist/2 + int(s.radius) + largest
+			specs = append(specs, addPlanets(&s.body, s.mass, planetAmount/2, maxSatellites/2, near, maxDist/3)...)
+		}
+	}
+
+	for i, t := range genPlanetTextures(specs) {
+		planets[i].texture = t
+	}
+}
+
+// addPlanets generates planets with satellites orbiting parent, which has
+// the given mass. It returns the specs of their textures.
+func addPlanets(parent *body, mass float64, planetAmount, maxSatellites, minDist, maxDist int) (specs []planetSpec) {
 	// We distribute the planets homogeneously on the X axis inside the given range (span).
 	span := maxDist - minDist
 	step := span / planetAmount
+	if step < 3 {
+		step = 3
+	}
 	current := minDist
 
 	for i := 0; i < planetAmount; i++ {
 		size, vel, dir := genPlanetParameters(planetSizes)
-		r := rand.Intn(len(sprites.planets))
-		p := newPlanet(float64(current), size, dir, pixel.V(vel, vel), origin, &players[0], sprites.planets[r])
-		// Add a little random adjustment to the planet's position to make
+		b := randomBiome(false)
+		// Add a little random adjustment to the planet's distance to make
 		// it look less static.
 		shift := float64(rand.Intn(step/3)*2 - step/3)
-		p.pos.X += shift
+		// The random phase distributes the planets nicely "on the clock".
+		p := newPlanet(genOrbit(float64(current)+shift, vel, dir, mass, maxEccentricity), size, parent, &players[0], b)
+		specs = append(specs, planetSpec{rand.Int63(), 30, b})
+		if hasRing(p) {
+			p.ring = newRing(b)
+		}
 		// The planet is generated. Add it to our global planets slice.
 		planets = append(planets, p)
 
@@ -103,25 +191,39 @@ func initSolarSystem(planetAmount, maxSatellites, minDist, maxDist int) {
 
 		for s := 0; s < sats; s++ {
 			size, vel, dir := genPlanetParameters(satelliteSizes)
-			r = rand.Intn(len(sprites.planets))
-			sat := newPlanet(float64((s+1)*20), size, dir, pixel.V(vel, vel), &p.pos, &players[0], sprites.planets[r])
-			sat.rotate(rand.Float64() * sat.dist)
-			p.satellites = append(p.satellites, sat)
+			b = randomBiome(true)
+			sat := newPlanet(genOrbit(float64((s+1)*20), vel, dir, p.mass, maxEccentricity/2), size, &p.body, &players[0], b)
+			specs = append(specs, planetSpec{rand.Int63(), 30, b})
 			planets = append(planets, sat)
 		}
 
-		// Now that the planet and its satellites exist we rotate them randomly
-		// to achieve a nice distribution "on the clock".
-		p.rotateGroup(rand.Float64() * p.dist)
-
 		// Next planet please..
 		current += step
 	}
+
+	return
+}
+
+// hasRing randomly decides if a planet gets a ring system. Only larger
+// planets have rings and gas giants are more likely to have them.
+func hasRing(p *planet) bool {
+	if p.radius < float64(planetSizes[1]) {
+		return false
+	}
+	chance := 0.25
+	if p.biome == biomeGasGiant {
+		chance = 0.7
+	}
+	return rand.Float64() < chance
 }
 
 // setFPS allows us to set max frames per second.
 // Disable any maximum by passing 0.
 func setFPS(fps int) {
+	if frameTick != nil {
+		frameTick.Stop()
+	}
+	fpsLimit = fps
 	if fps <= 0 {
 		frameTick = nil
 	} else {
@@ -132,9 +234,18 @@ func setFPS(fps int) {
 // update handles all logic changes in the game. This
 // includes moving objects or handling input.
 func update(dt float64) {
+	gameTime += dt
+
+	system.move(gameTime)
+	for _, s := range stars {
+		s.update(dt)
+	}
+
 	for i := 0; i < len(planets); i++ {
 		planets[i].update(dt)
 	}
+	updateIndex()
+	updateFleets(dt)
 }
 
 // draw is called after update and just draws
@@ -142,15 +253,46 @@ func update(dt float64) {
 func draw() {
 	// Clear everything before drawing.
 	window.Clear(colornames.Black)
+
+	if gameRunning {
+		bg.draw(window, window.Bounds())
+		drawWorld()
+	}
+	if state == stateMenu {
+		menu.draw(window)
+	}
+}
+
+// drawWorld draws all game objects and the HUD.
+func drawWorld() {
 	worldCanvas.Clear(pixel.Alpha(0))
+	batches.planets.Clear()
 	batches.ships.Clear()
+	overlay.Clear()
+
+	// Orbits and markers are drawn below the planets.
+	if showOrbits {
+		drawOrbits(overlay)
+	}
+	if aimSource != nil {
+		if target := planetAt(screenToWorld(window.MousePosition())); target != nil && target != aimSource {
+			drawArrivalPreview(overlay, aimSource, target)
+		}
+	}
 
 	// Draw the game objects onto the canvas.
-	sprites.sun.Draw(worldCanvas, pixel.IM)
+	for _, s := range stars {
+		s.draw()
+	}
 	for _, p := range planets {
 		p.draw(cam)
 	}
+	for _, f := range fleets {
+		f.draw()
+	}
 
+	overlay.Draw(worldCanvas)
+	batches.planets.Draw(worldCanvas)
 	batches.ships.Draw(worldCanvas)
 
 	// // Draw the canvas onto the window.
@@ -163,23 +305,56 @@ func draw() {
 	objectsText.Clear()
 	objectsText.WriteString(fmt.Sprintf("Objects: %d", objectCount))
 	objectsText.Draw(window, pixel.IM)
+	speedText.Clear()
+	if paused {
+		speedText.WriteString("PAUSED")
+	} else {
+		speedText.WriteString(fmt.Sprintf("Speed: %gx", speeds[speedIndex]))
+	}
+	speedText.Draw(window, pixel.IM)
+	formationText.Clear()
+	formationText.WriteString(fmt.Sprintf("Formation: %s", fleetFormation))
+	formationText.Draw(window, pixel.IM)
+	seedText.Clear()
+	seedText.WriteString(fmt.Sprintf("Seed: %d", seed))
+	seedText.Draw(window, pixel.IM)
+}
+
+// initHUD creates all texts of the heads-up display.
+func initHUD() {
+	line := fonts.small.LineHeight()
+	top := window.Bounds().H() - uiPadding - fonts.small.Ascent()
+
+	fpsText = text.New(pixel.V(uiPadding, top), fonts.small)
+	fpsText.Color = colornames.Antiquewhite
+	objectsText = text.New(pixel.V(uiPadding, top-line), fonts.small)
+	objectsText.Color = colornames.Antiquewhite
+	speedText = text.New(pixel.V(uiPadding, top-2*line), fonts.small)
+	speedText.Color = colornames.Antiquewhite
+	formationText = text.New(pixel.V(uiPadding, top-3*line), fonts.small)
+	formationText.Color = colornames.Antiquewhite
+	seedText = text.New(pixel.V(uiPadding, top-4*line), fonts.small)
+	seedText.Color = colornames.Antiquewhite
 }
 
 func run() {
 	rand.Seed(time.Now().UnixNano())
 
+	// The settings have to be known before anything else is set up.
+	if err := loadSettings(); err != nil {
+		fmt.Fprintln(os.Stderr, "could not load settings:", err)
+	}
+
 	// First call all init functions to setup the game.
-	initScreen()
-	setFPS(0)
+	if err := initScreen(); err != nil {
+		fmt.Fprintln(os.Stderr, "could not create window:", err)
+		os.Exit(1)
+	}
 	initPlayers("RagingDave", 0)
-	genSprites(10)
-	initSolarSystem(8, 3, 100, int(screenHeight/2))
-
-	// TODO init texts in extra function at some point.
-	fpsText = text.New(pixel.V(10, window.Bounds().H()-20), text.Atlas7x13)
-	fpsText.Color = colornames.Antiquewhite
-	objectsText = text.New(pixel.V(10, window.Bounds().H()-40), text.Atlas7x13)
-	objectsText.Color = colornames.Antiquewhite
+	genSprites()
+	initFonts()
+	initHUD()
+	showMainMenu()
 
 	start := time.Now()
 	now := start
@@ -190,7 +365,13 @@ func run() {
 
 		fps = float64(frames) / now.Sub(start).Seconds()
 
-		update(dt)
+		if window.Bounds() != worldCanvas.Bounds() {
+			handleResize()
+		}
+		handleInput(dt)
+		if state == statePlaying && !paused {
+			update(dt * simSpeed())
+		}
 		draw()
 
 		frames++
@@ -203,5 +384,14 @@ func run() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "sprites" {
+		if err := exportSprites(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, "could not export sprites:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	flag.Int64Var(&firstSeed, "seed", 0, "seed of the first game, random if 0")
+	flag.Parse()
 	pixelgl.Run(run)
 }
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/pixelgl"
+)
+
+// The widths of the main menu and of one column of the settings screen at
+// a UI scale of 1.
+const (
+	menuWidth     = 260.0
+	settingsWidth = 360.0
+)
+
+// gameState determines which screen is active.
+type gameState int
+
+const (
+	stateMenu gameState = iota
+	statePlaying
+)
+
+// showMainMenu builds the main menu and switches to it. If a game is in
+// progress it can be resumed from the menu.
+func showMainMenu() {
+	rows := 4
+	if gameRunning {
+		rows++
+	}
+	row := fonts.normal.LineHeight() + 2*uiPadding
+	titleRow := fonts.large.LineHeight() + 2*uiPadding
+	width := menuWidth * uiScale
+	height := titleRow + float64(rows)*(row+uiPadding) + 2*uiPadding
+	center := window.Bounds().Center()
+
+	menu = newUI(window.Bounds(), fonts.normal)
+	p := menu.add(newPanel(pixel.R(center.X-width/2, center.Y-height/2, center.X+width/2, center.Y+height/2), true))
+	t := newLabel(title, true)
+	t.atlas = fonts.large
+	p.add(t, titleRow)
+	if gameRunning {
+		p.add(newButton("Resume", resumeGame), row)
+	}
+	p.add(newButton("New Game", newGame), row)
+	// There are no save games yet.
+	p.add(newButton("Load", nil), row).base().disabled = true
+	p.add(newButton("Settings", showSettings), row)
+	p.add(newButton("Quit", func() { window.SetClosed(true) }), row)
+	menu.cycleFocus(1)
+	if gameRunning {
+		menu.onBack = resumeGame
+	}
+
+	menuScreen = showMainMenu
+	state = stateMenu
+}
+
+// showSettings opens the settings screen with the current settings.
+func showSettings() {
+	settingsDraft = currentSettings()
+	showSettingsScreen()
+}
+
+// showSettingsScreen builds the settings screen for settingsDraft. Changes
+// are only applied and saved when the user asks for it.
+func showSettingsScreen() {
+	s := &settingsDraft
+	var left, right []widget
+
+	left = append(left, newLabel("Video", true))
+
+	// The current size is offered too, even if it is none of the presets.
+	sizes := append([][2]int{}, resolutions...)
+	size := -1
+	for i, r := range sizes {
+		if r[0] == s.Width && r[1] == s.Height {
+			size = i
+		}
+	}
+	if size < 0 {
+		sizes = append(sizes, [2]int{s.Width, s.Height})
+		size = len(sizes) - 1
+	}
+	var sizeNames []string
+	for _, r := range sizes {
+		sizeNames = append(sizeNames, fmt.Sprintf("%dx%d", r[0], r[1]))
+	}
+	res := newChoice("Resolution", sizeNames, &size)
+	res.onChange = func() {
+		s.Width, s.Height = sizes[size][0], sizes[size][1]
+	}
+	left = append(left, res)
+
+	mode := int(s.Display)
+	modes := newChoice("Display", []string{
+		displayWindowed.String(),
+		displayFullscreen.String(),
+		displayBorderless.String(),
+	}, &mode)
+	modes.onChange = func() {
+		s.Display = displayMode(mode)
+	}
+	left = append(left, modes)
+
+	var monitorNames []string
+	for i, m := range pixelgl.Monitors() {
+		monitorNames = append(monitorNames, fmt.Sprintf("%d %s", i+1, m.Name()))
+	}
+	left = append(left, newChoice("Monitor", monitorNames, &s.Monitor), newCheckbox("VSync", &s.VSync))
+
+	fpsCap := float64(s.FPS)
+	fpsSlider := newSlider("FPS cap", &fpsCap, 0, maxFPS, 10)
+	fpsSlider.valueText = func(v float64) string {
+		if v <= 0 {
+			return "none"
+		}
+		return fmt.Sprintf("%d", int(v))
+	}
+	fpsSlider.onChange = func() {
+		s.FPS = int(fpsCap)
+	}
+	left = append(left, fpsSlider)
+
+	scale := newSlider("UI scale", &s.UIScale, minUIScale, maxUIScale, 0.25)
+	scale.valueText = func(v float64) string {
+		return fmt.Sprintf("%gx", v)
+	}
+	left = append(left,
+		scale,
+		newCheckbox("Colorblind colors", &s.Colorblind),
+		newCheckbox("Rotating planets", &s.RotatePlanets),
+		newCheckbox("Realistic orbits", &s.KeplerOrbits),
+		newCheckbox("Orbit lines", &s.ShowOrbits),
+	)
+
+	percent := func(v float64) string {
+		return fmt.Sprintf("%d%%", int(math.Round(v*100)))
+	}
+	right = append(right, newLabel("Audio", true))
+	music := newSlider("Music", &s.MusicVolume, 0, 1, 0.05)
+	music.valueText = percent
+	right = append(right, music)
+	effects := newSlider("Effects", &s.EffectsVolume, 0, 1, 0.05)
+	effects.valueText = percent
+	right = append(right, effects)
+
+	right = append(right, newLabel("Keys", true))
+	for a, name := range actionNames {
+		name := name
+		key, ok := buttonByName(s.Keys[name])
+		if !ok {
+			key = keys[a]
+		}
+		bound := &key
+		k := newKeyInput(name, bound)
+		k.onChange = func() {
+			s.Keys[name] = bound.String()
+		}
+		right = append(right, k)
+	}
+
+	apply := newButton("Apply", func() {
+		if err := settingsDraft.apply(); err != nil {
+			fmt.Fprintln(os.Stderr, "could not apply settings:", err)
+			settingsDraft = currentSettings()
+		}
+		if err := saveSettings(); err != nil {
+			fmt.Fprintln(os.Stderr, "could not save settings:", err)
+		}
+		menuScreen()
+	})
+	right = append(right, apply, newButton("Back", showMainMenu))
+
+	menu = newUI(window.Bounds(), fonts.normal)
+	menu.onBack = showMainMenu
+	addColumns(menu, [][]widget{left, right}, settingsWidth*uiScale)
+	menu.cycleFocus(1)
+
+	menuScreen = showSettingsScreen
+	state = stateMenu
+}
+
+// addColumns adds a panel of the given width for each of the columns to u,
+// next to each other and centered in the window. Columns that are too high
+// for the window are split into several ones and narrowed if they do not
+// fit next to each other. A label starts a group, so it is never left at
+// the bottom of a column.
+func addColumns(u *ui, columns [][]widget, width float64) {
+	row := fonts.normal.LineHeight() + 2*uiPadding
+	bounds := window.Bounds()
+	perColumn := int((bounds.H() - 3*uiPadding) / (row + uiPadding))
+	if perColumn < 1 {
+		perColumn = 1
+	}
+
+	var split [][]widget
+	for _, ws := range columns {
+		var col []widget
+		for _, w := range ws {
+			_, group := w.(*label)
+			full := len(col) == perColumn || group && len(col) == perColumn-1
+			if full && len(col) > 0 {
+				split = append(split, col)
+				col = nil
+			}
+			col = append(col, w)
+		}
+		split = append(split, col)
+	}
+	rows := 0
+	for _, col := range split {
+		if len(col) > rows {
+			rows = len(col)
+		}
+	}
+
+	n := float64(len(split))
+	if fit := (bounds.W() - (n+1)*uiPadding) / n; width > fit {
+		width = fit
+	}
+	height := float64(rows)*(row+uiPadding) + uiPadding
+	center := bounds.Center()
+	bottom, top := center.Y-height/2, center.Y+height/2
+	x := center.X - (n*width+(n-1)*uiPadding)/2
+	for _, col := range split {
+		p := u.add(newPanel(pixel.R(x, bottom, x+width, top), true))
+		for _, w := range col {
+			p.add(w, row)
+		}
+		x += width + uiPadding
+	}
+}
+
+// newGame throws away the current game (if any) and starts a new one.
+func newGame() {
+	planets = nil
+	aimSource = nil
+	fleets = nil
+	recycledShips = []*ship{}
+	objectCount = 0
+	gameTime = 0
+	paused = false
+	speedIndex = 2
+	camPos = pixel.ZV
+	moveCamera(pixel.ZV)
+
+	// Everything generated from here on has to be derived from the seed
+	// so the same seed gives the same map.
+	seed, firstSeed = firstSeed, 0
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rand.Seed(seed)
+	bg = genBackground(seed)
+	sprites.atlas.reset(sprites.atlasStatic)
+	initSolarSystem(8, 3, 100, int(screenHeight/2))
+	updateIndex()
+
+	gameRunning = true
+	state = statePlaying
+}
+
+// resumeGame closes the menu and continues the current game.
+func resumeGame() {
+	state = statePlaying
+}
@@ -0,0 +1,238 @@
+// Package noise generates coherent noise for procedural content. It builds
+// fractal variants (fBm, ridged multifractal, turbulence), domain warping
+// and tileable noise on top of OpenSimplex noise.
+//
+// A Noise is read-only after it is created, so all methods are safe for
+// concurrent use.
+package noise
+
+import (
+	"math"
+
+	opensimplex "github.com/ojrac/opensimplex-go"
+)
+
+// Noise is a seeded noise generator. Equal seeds always give equal noise.
+type Noise struct {
+	seed int64
+	n    *opensimplex.Noise
+}
+
+// New creates a noise generator from a seed.
+func New(seed int64) *Noise {
+	return &Noise{seed: seed, n: opensimplex.NewWithSeed(seed)}
+}
+
+// Seed returns the seed the generator was created with.
+func (n *Noise) Seed() int64 {
+	return n.seed
+}
+
+// Eval2 returns plain 2D noise in [-1, 1].
+func (n *Noise) Eval2(x, y float64) float64 {
+	return n.n.Eval2(x, y)
+}
+
+// Eval3 returns plain 3D noise in [-1, 1].
+func (n *Noise) Eval3(x, y, z float64) float64 {
+	return n.n.Eval3(x, y, z)
+}
+
+// Eval4 returns plain 4D noise in [-1, 1].
+func (n *Noise) Eval4(x, y, z, w float64) float64 {
+	return n.n.Eval4(x, y, z, w)
+}
+
+// Fractal configures how octaves of noise are layered. Every octave has
+// Lacunarity times the frequency and Persistence times the amplitude of
+// the previous one. The zero value is a single octave at frequency 1.
+type Fractal struct {
+	// Octaves defaults to 1 if it is less than 1.
+	Octaves int
+	// Frequency defaults to 1 if it is 0.
+	Frequency   float64
+	Persistence float64
+	// Lacunarity defaults to 2 if it is 0.
+	Lacunarity float64
+}
+
+func (f Fractal) octaveCount() int {
+	if f.Octaves < 1 {
+		return 1
+	}
+	return f.Octaves
+}
+
+func (f Fractal) frequency() float64 {
+	if f.Frequency == 0 {
+		return 1
+	}
+	return f.Frequency
+}
+
+func (f Fractal) lacunarity() float64 {
+	if f.Lacunarity == 0 {
+		return 2
+	}
+	return f.Lacunarity
+}
+
+// octaves sums the octaves returned by eval, which gets the frequency of
+// the octave. The result is normalized by the sum of the amplitudes.
+func (f Fractal) octaves(eval func(freq float64) float64) float64 {
+	result, ampSum := 0.0, 0.0
+	amp, freq := 1.0, f.frequency()
+
+	for i := 0; i < f.octaveCount(); i++ {
+		result += eval(freq) * amp
+		ampSum += amp
+		amp *= f.Persistence
+		freq *= f.lacunarity()
+	}
+
+	return result / ampSum
+}
+
+// FBm2 returns fractal Brownian motion: layered octaves of 2D noise. The
+// result is in [-1, 1].
+func (n *Noise) FBm2(f Fractal, x, y float64) float64 {
+	return f.octaves(func(freq float64) float64 {
+		return n.n.Eval2(x*freq, y*freq)
+	})
+}
+
+// FBm3 is the 3D counterpart of FBm2.
+func (n *Noise) FBm3(f Fractal, x, y, z float64) float64 {
+	return f.octaves(func(freq float64) float64 {
+		return n.n.Eval3(x*freq, y*freq, z*freq)
+	})
+}
+
+// Turbulence2 layers the absolute values of 2D noise, which creates sharp
+// valleys like in billowing clouds or smoke. The result is in [0, 1].
+func (n *Noise) Turbulence2(f Fractal, x, y float64) float64 {
+	return f.octaves(func(freq float64) float64 {
+		return math.Abs(n.n.Eval2(x*freq, y*freq))
+	})
+}
+
+// Turbulence3 is the 3D counterpart of Turbulence2.
+func (n *Noise) Turbulence3(f Fractal, x, y, z float64) float64 {
+	return f.octaves(func(freq float64) float64 {
+		return math.Abs(n.n.Eval3(x*freq, y*freq, z*freq))
+	})
+}
+
+// ridged sums octaves of ridged noise. Every octave is weighted by the
+// previous one, so details gather on the ridges like in mountain ranges.
+func (f Fractal) ridged(eval func(freq float64) float64) float64 {
+	result, ampSum := 0.0, 0.0
+	amp, freq, weight := 1.0, f.frequency(), 1.0
+
+	for i := 0; i < f.octaveCount(); i++ {
+		r := 1 - math.Abs(eval(freq))
+		r *= r * weight
+		weight = math.Min(1, math.Max(0, r*2))
+
+		result += r * amp
+		ampSum += amp
+		amp *= f.Persistence
+		freq *= f.lacunarity()
+	}
+
+	return result / ampSum
+}
+
+// Ridged2 returns ridged multifractal 2D noise in [0, 1].
+func (n *Noise) Ridged2(f Fractal, x, y float64) float64 {
+	return f.ridged(func(freq float64) float64 {
+		return n.n.Eval2(x*freq, y*freq)
+	})
+}
+
+// Ridged3 is the 3D counterpart of Ridged2.
+func (n *Noise) Ridged3(f Fractal, x, y, z float64) float64 {
+	return f.ridged(func(freq float64) float64 {
+		return n.n.Eval3(x*freq, y*freq, z*freq)
+	})
+}
+
+// Offsets between the noise samples used for domain warping, so the
+// displacement along each axis is independent.
+const (
+	warpOffsetX = 5.2
+	warpOffsetY = 1.3
+	warpOffsetZ = 8.7
+)
+
+// Warp2 returns fBm noise at a position that is displaced by fBm noise
+// itself, which creates swirly, organic shapes. strength is the maximum
+// displacement in noise coordinates.
+func (n *Noise) Warp2(f Fractal, strength, x, y float64) float64 {
+	dx := n.FBm2(f, x, y)
+	dy := n.FBm2(f, x+warpOffsetX, y+warpOffsetY)
+	return n.FBm2(f, x+strength*dx, y+strength*dy)
+}
+
+// Warp3 is the 3D counterpart of Warp2.
+func (n *Noise) Warp3(f Fractal, strength, x, y, z float64) float64 {
+	dx := n.FBm3(f, x, y, z)
+	dy := n.FBm3(f, x+warpOffsetX, y+warpOffsetY, z+warpOffsetZ)
+	dz := n.FBm3(f, x+warpOffsetY, y+warpOffsetZ, z+warpOffsetX)
+	return n.FBm3(f, x+strength*dx, y+strength*dy, z+strength*dz)
+}
+
+// Tileable2 returns fBm noise that repeats every w units along X and every
+// h units along Y. Both axes are wrapped around circles in 4D noise, so
+// there are no visible seams or loss of contrast.
+func (n *Noise) Tileable2(f Fractal, x, y, w, h float64) float64 {
+	// The circumference of the circles equals the period, so features
+	// have the same size as in FBm2.
+	rx, ry := w/(2*math.Pi), h/(2*math.Pi)
+	sx, cx := math.Sincos(x / w * 2 * math.Pi)
+	sy, cy := math.Sincos(y / h * 2 * math.Pi)
+
+	return f.octaves(func(freq float64) float64 {
+		return n.n.Eval4(cx*rx*freq, sx*rx*freq, cy*ry*freq, sy*ry*freq)
+	})
+}
+
+// Tileable3 returns fBm noise that repeats every w, h and d units along
+// X, Y and Z. It blends the noise of the neighbouring periods, which
+// slightly reduces the contrast in the middle of a tile.
+func (n *Noise) Tileable3(f Fractal, x, y, z, w, h, d float64) float64 {
+	x, y, z = wrap(x, w), wrap(y, h), wrap(z, d)
+	fx, fy, fz := x/w, y/h, z/d
+
+	result := 0.0
+	for i := 0; i < 8; i++ {
+		px, wx := x, 1-fx
+		if i&1 != 0 {
+			px, wx = x-w, fx
+		}
+		py, wy := y, 1-fy
+		if i&2 != 0 {
+			py, wy = y-h, fy
+		}
+		pz, wz := z, 1-fz
+		if i&4 != 0 {
+			pz, wz = z-d, fz
+		}
+		result += n.FBm3(f, px, py, pz) * wx * wy * wz
+	}
+	return result
+}
+
+// wrap returns v modulo period in [0, period).
+func wrap(v, period float64) float64 {
+	v = math.Mod(v, period)
+	if v < 0 {
+		v += period
+	}
+	return v
+}
+
+// Map maps a value from [-1, 1] to [low, high].
+func Map(v, low, high float64) float64 {
+	return v*(high-low)/2 + (high+low)/2
+}
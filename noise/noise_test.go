@@ -0,0 +1,159 @@
+package noise
+
+import (
+	"math"
+	"testing"
+)
+
+var testFractals = []Fractal{
+	{},
+	{Octaves: 1, Frequency: 0.5},
+	{Octaves: 4, Frequency: 3, Persistence: 0.5},
+	{Octaves: 8, Frequency: 1.5, Persistence: 0.7, Lacunarity: 2.5},
+}
+
+// testPoints returns a grid of points around the origin, including
+// negative and fractional coordinates.
+func testPoints() (ps [][3]float64) {
+	for x := -5.0; x <= 5; x += 0.9 {
+		for y := -5.0; y <= 5; y += 1.3 {
+			for z := -2.0; z <= 2; z += 1.7 {
+				ps = append(ps, [3]float64{x, y, z})
+			}
+		}
+	}
+	return ps
+}
+
+// funcs are all noise functions of a Noise with a Fractal, reduced to
+// three coordinates.
+var funcs = []struct {
+	name      string
+	low, high float64
+	fn        func(n *Noise, f Fractal, x, y, z float64) float64
+}{
+	{"Eval2", -1, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Eval2(x, y) }},
+	{"Eval3", -1, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Eval3(x, y, z) }},
+	{"Eval4", -1, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Eval4(x, y, z, x-y) }},
+	{"FBm2", -1, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.FBm2(f, x, y) }},
+	{"FBm3", -1, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.FBm3(f, x, y, z) }},
+	{"Turbulence2", 0, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Turbulence2(f, x, y) }},
+	{"Turbulence3", 0, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Turbulence3(f, x, y, z) }},
+	{"Ridged2", 0, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Ridged2(f, x, y) }},
+	{"Ridged3", 0, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Ridged3(f, x, y, z) }},
+	{"Warp2", -1, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Warp2(f, 0.5, x, y) }},
+	{"Warp3", -1, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Warp3(f, 0.5, x, y, z) }},
+	{"Tileable2", -1, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Tileable2(f, x, y, 4, 3) }},
+	{"Tileable3", -1, 1, func(n *Noise, f Fractal, x, y, z float64) float64 { return n.Tileable3(f, x, y, z, 4, 3, 2) }},
+}
+
+func TestDeterminism(t *testing.T) {
+	for _, seed := range []int64{0, 1, 42, -7} {
+		a, b, other := New(seed), New(seed), New(seed+1)
+		if a.Seed() != seed {
+			t.Errorf("New(%d).Seed() = %d", seed, a.Seed())
+		}
+		for _, fn := range funcs {
+			differs := false
+			for _, f := range testFractals {
+				for _, p := range testPoints() {
+					va := fn.fn(a, f, p[0], p[1], p[2])
+					if vb := fn.fn(b, f, p[0], p[1], p[2]); va != vb {
+						t.Fatalf("%s with seed %d at %v: %v and %v", fn.name, seed, p, va, vb)
+					}
+					if fn.fn(other, f, p[0], p[1], p[2]) != va {
+						differs = true
+					}
+				}
+			}
+			if !differs {
+				t.Errorf("%s gives the same noise for the seeds %d and %d", fn.name, seed, seed+1)
+			}
+		}
+	}
+}
+
+func TestRanges(t *testing.T) {
+	n := New(1)
+	for _, fn := range funcs {
+		for _, f := range testFractals {
+			for _, p := range testPoints() {
+				v := fn.fn(n, f, p[0], p[1], p[2])
+				if !(v >= fn.low && v <= fn.high) {
+					t.Fatalf("%s(%+v) at %v = %v, want in [%v, %v]", fn.name, f, p, v, fn.low, fn.high)
+				}
+			}
+		}
+	}
+}
+
+func TestZeroFractal(t *testing.T) {
+	n := New(3)
+	for _, p := range testPoints() {
+		if got, want := n.FBm2(Fractal{}, p[0], p[1]), n.Eval2(p[0], p[1]); got != want {
+			t.Fatalf("FBm2(Fractal{}) at %v = %v, want %v", p, got, want)
+		}
+		if got, want := n.FBm3(Fractal{}, p[0], p[1], p[2]), n.Eval3(p[0], p[1], p[2]); got != want {
+			t.Fatalf("FBm3(Fractal{}) at %v = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestTileable(t *testing.T) {
+	// Wrapping the coordinates is not exact in floating point math, and
+	// OpenSimplex has tiny jumps where it crosses lattice borders. Values
+	// that do not tile differ by far more.
+	const eps = 1e-3
+	n := New(5)
+	tests := []struct {
+		w, h, d float64
+	}{
+		{1, 1, 1},
+		{4, 3, 2},
+		{10.5, 7.25, 3},
+	}
+	for _, test := range tests {
+		for _, f := range testFractals {
+			for _, p := range testPoints() {
+				x, y, z := p[0], p[1], p[2]
+
+				v := n.Tileable2(f, x, y, test.w, test.h)
+				for _, q := range [][2]float64{
+					{x + test.w, y}, {x - test.w, y}, {x, y + test.h}, {x + 2*test.w, y - 3*test.h},
+				} {
+					if got := n.Tileable2(f, q[0], q[1], test.w, test.h); math.Abs(got-v) > eps {
+						t.Fatalf("Tileable2(%+v, %v, %v) period %vx%v at %v = %v, want %v",
+							f, x, y, test.w, test.h, q, got, v)
+					}
+				}
+
+				v = n.Tileable3(f, x, y, z, test.w, test.h, test.d)
+				for _, q := range [][3]float64{
+					{x + test.w, y, z}, {x, y - test.h, z}, {x, y, z + test.d}, {x - test.w, y + 2*test.h, z - test.d},
+				} {
+					if got := n.Tileable3(f, q[0], q[1], q[2], test.w, test.h, test.d); math.Abs(got-v) > eps {
+						t.Fatalf("Tileable3(%+v, %v) period %vx%vx%v at %v = %v, want %v",
+							f, p, test.w, test.h, test.d, q, got, v)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMap(t *testing.T) {
+	tests := []struct {
+		v, low, high, want float64
+	}{
+		{-1, 0, 1, 0},
+		{1, 0, 1, 1},
+		{0, 0, 1, 0.5},
+		{0.5, -0.5, 1.2, 0.775},
+		{-1, 10, 20, 10},
+	}
+	for _, test := range tests {
+		if got := Map(test.v, test.low, test.high); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("Map(%v, %v, %v) = %v, want %v", test.v, test.low, test.high, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+)
+
+const (
+	// orbitSegments is the number of lines an orbit path is made of.
+	orbitSegments = 96
+	// orbitAlpha is the opacity of orbit paths.
+	orbitAlpha = 0.25
+	// ghostAlpha is the opacity of the arrival preview.
+	ghostAlpha = 0.6
+)
+
+// path returns points along the whole orbit relative to the anchor. The
+// points are spaced evenly by eccentric anomaly, which is dense enough at
+// both ends of an ellipse.
+func (o orbit) path(segments int) []pixel.Vec {
+	semiMinor := o.semiMajor * math.Sqrt(1-o.eccentricity*o.eccentricity)
+	points := make([]pixel.Vec, segments+1)
+	for i := range points {
+		ecc := float64(i) / float64(segments) * 2 * math.Pi
+		v := pixel.V(o.semiMajor*(math.Cos(ecc)-o.eccentricity), semiMinor*math.Sin(ecc))
+		points[i] = v.Rotated(o.periapsis)
+	}
+	return points
+}
+
+// drawOrbits draws the orbit paths of all planets in their owner's color.
+func drawOrbits(imd *imdraw.IMDraw) {
+	for _, p := range planets {
+		if p.semiMajor == 0 {
+			continue
+		}
+		imd.Color = pixel.ToRGBA(p.player.color).Scaled(orbitAlpha)
+		for _, v := range p.path(orbitSegments) {
+			imd.Push(p.parent.pos.Add(v))
+		}
+		imd.Line(1)
+	}
+}
+
+// planetAt returns the planet at the world position pos or nil.
+func planetAt(pos pixel.Vec) (found *planet) {
+	// Small planets are hard to hit, so be generous.
+	bodyGrid.near(pos, 4, func(e spatialEntry) bool {
+		found = e.planet
+		return found == nil
+	})
+	return
+}
+
+// screenToWorld converts a position in the window to world coordinates.
+// The world canvas is drawn with the camera matrix and the camera is also
+// applied inside the canvas, so both have to be undone.
+func screenToWorld(v pixel.Vec) pixel.Vec {
+	local := cam.Unproject(v).Add(worldCanvas.Bounds().Center())
+	return cam.Unproject(local)
+}
+
+// drawArrivalPreview draws a ghost of the target at the position it will
+// be at when a fleet from the aimed source arrives, and the path there.
+// Without an intercept course the fleet would home in on the target, so
+// the ghost stays at the target.
+func drawArrivalPreview(imd *imdraw.IMDraw, source, target *planet) {
+	_, eta, ok := intercept(source.pos, fleetSpeed, target)
+	ghost := target.at(gameTime + eta)
+	if !ok {
+		ghost = target.pos
+	}
+
+	imd.Color = pixel.ToRGBA(source.player.color).Scaled(ghostAlpha)
+	imd.Push(source.pos, ghost)
+	imd.Line(1)
+	imd.Color = pixel.ToRGBA(target.player.color).Scaled(ghostAlpha)
+	imd.Push(ghost)
+	imd.Circle(target.radius, 1)
+}
@@ -3,100 +3,418 @@ package main
 import (
 	"image/color"
 	"math"
-	"time"
+	"math/rand"
 
 	"github.com/faiface/pixel"
 	"github.com/faiface/pixel/pixelgl"
-	opensimplex "github.com/ojrac/opensimplex-go"
 	"golang.org/x/image/colornames"
+
+	"github.com/dbriemann/gonk/noise"
 )
 
-// layerNoise creates noise from multiple layers of simplex noise.
-// See https://cmaher.github.io/posts/working-with-simplex-noise/ for the original function.
-func layerNoise(layers int, x, y, persistence, freq, low, high float64) (result float64) {
-	ampSum := 0.0
-	amp := 1.0
-
-	for i := 0; i < layers; i++ {
-		result += noise.Eval2(x*freq, y*freq) * amp
-		ampSum += amp
-		amp *= persistence
-		freq *= 2
-	}
+// Fractal noise settings of the generators.
+var (
+	sunSurface    = noise.Fractal{Octaves: 4, Frequency: 3, Persistence: 0.5}
+	coronaStreaks = noise.Fractal{Octaves: 3, Frequency: 1, Persistence: 0.5}
+	ringBands     = noise.Fractal{Octaves: 4, Frequency: 0.15, Persistence: 0.5}
+	gasGiantBands = noise.Fractal{Octaves: 6, Frequency: 1, Persistence: 0.5}
+	planetTerrain = noise.Fractal{Octaves: 8, Frequency: 1.5, Persistence: 0.5}
+)
 
-	result /= ampSum
+func genGradientDisc(radius, density float64, c color.Color) (canvas *pixelgl.Canvas) {
+	size := int(radius*2 + 1)
+	canvas = pixelgl.NewCanvas(pixel.R(0, 0, float64(size), float64(size)))
+	canvas.SetPixels(gradientDisc(radius, density, c))
 
-	result = result*(high-low)/2 + (high+low)/2
 	return
 }
 
-func brighten(val uint8, factor float64) uint8 {
-	r := float64(val) * factor
-	if uint8(r) < val {
-		return 255
-	}
-	return uint8(r)
+// gradientDisc returns the pixels of the disc generated by genGradientDisc.
+// The disc has the color c up to density (relative to the radius) and
+// fades out linearly from there. It does not need a canvas and can be used
+// from any goroutine.
+func gradientDisc(radius, density float64, c color.Color) (pixels []uint8) {
+	pixels, _ = radialGradientPixels(radialGradient{
+		radius: radius,
+		stops:  gradient{{density, pixel.ToRGBA(c)}, {1, pixel.Alpha(0)}},
+	})
+
+	return
 }
 
-func genGradientDisc(radius, density float64, c color.Color) (canvas *pixelgl.Canvas) {
-	cr, cg, cb, ca := c.RGBA()
+// genShadow generates the night side of a planet as an overlay for its
+// sprite. The sun is in the direction of the positive X axis, so the
+// sprite has to be rotated towards the sun when drawing it.
+func genShadow(radius float64) (canvas *pixelgl.Canvas) {
 	size := int(radius*2 + 1)
 	canvas = pixelgl.NewCanvas(pixel.R(0, 0, float64(size), float64(size)))
-	pixels := canvas.Pixels()
+	canvas.SetPixels(shadowPixels(radius))
 
-	ncol := pixel.RGBA{
-		R: float64(cr) / 0xffff,
-		G: float64(cg) / 0xffff,
-		B: float64(cb) / 0xffff,
-		A: float64(ca) / 0xffff,
-	}
+	return
+}
+
+// shadowPixels returns the pixels of the shadow generated by genShadow.
+func shadowPixels(radius float64) (pixels []uint8) {
+	size := int(radius*2 + 1)
+	pixels = make([]uint8, size*size*4)
 
 	for y := 0; y < size; y++ {
 		for x := 0; x < size; x++ {
-			dist := pixel.V(float64(x), float64(y)).Sub(pixel.V(radius, radius)).Len()
-			factor := (dist - radius*density) / (radius * (1 - density))
-			factor = math.Min(1, math.Max(0, factor)) // clamp
+			dx := (float64(x) - radius) / radius
+			dy := (float64(y) - radius) / radius
+			d := dx*dx + dy*dy
+			if d > 1 {
+				continue
+			}
+			// The surface normal is (dx, dy, sqrt(1-d)) and the light
+			// comes from (1, 0, 0), so the lighting is just dx.
+			// The night side is not completely dark.
+			dark := (1 - smoothstep(-0.15, 0.25, dx)) * 0.85
 
 			index := y*size*4 + x*4
-			pixels[index] = uint8(ncol.R * (1 - factor) * 255)
-			pixels[index+1] = uint8(ncol.G * (1 - factor) * 255)
-			pixels[index+2] = uint8(ncol.B * (1 - factor) * 255)
-			pixels[index+3] = uint8(ncol.A * (1 - factor) * 255)
+			pixels[index+3] = uint8(dark * 255)
 		}
 	}
 
-	canvas.SetPixels(pixels)
+	return
+}
+
+// genAtmosphere generates a white glow around a planet of the given radius
+// that is strongest on the day side. Like the shadow it is lit from the
+// positive X axis. thickness is relative to the radius.
+func genAtmosphere(radius, thickness float64) (canvas *pixelgl.Canvas) {
+	outer := radius * (1 + thickness)
+	size := int(outer*2 + 1)
+	canvas = pixelgl.NewCanvas(pixel.R(0, 0, float64(size), float64(size)))
+	canvas.SetPixels(atmospherePixels(radius, thickness))
+
+	return
+}
+
+// atmospherePixels returns the pixels of the glow generated by genAtmosphere.
+func atmospherePixels(radius, thickness float64) (pixels []uint8) {
+	outer := radius * (1 + thickness)
+	edge := radius / outer
+	pixels, _ = radialGradientPixels(radialGradient{
+		radius: outer,
+		// The glow peaks at the planet's edge and fades out to both sides.
+		stops: gradient{
+			{edge * 0.8, pixel.Alpha(0)},
+			{edge, pixel.Alpha(1)},
+			{1, pixel.Alpha(0)},
+		},
+		ease: easeSmoothstep,
+		intensity: func(dir pixel.Vec) float64 {
+			return 0.2 + 0.8*smoothstep(-0.5, 0.5, dir.X)
+		},
+	})
 
 	return
 }
 
-func genPlanet(radius float64) (canvas *pixelgl.Canvas) {
-	noise = opensimplex.NewWithSeed(time.Now().UnixNano())
+// sunTexture is the animated surface of a star and its corona. Both are
+// sprites in the sprite atlas.
+type sunTexture struct {
+	sprite *pixel.Sprite
+	corona *pixel.Sprite
+	radius float64
+	grad   gradient
+	noise  *noise.Noise
+
+	pixels []uint8
+	// The time the surface was rendered for.
+	rendered float64
+}
+
+// sunFrameTime is the minimum time between two frames of the surface
+// animation. Rendering the surface is too expensive to do it every frame.
+const sunFrameTime = 1.0 / 15
+
+// genSun generates the surface and corona of a star of the given class.
+func genSun(n *noise.Noise, class starClass) *sunTexture {
+	params := starClasses[class]
+	size := int(params.radius*2 + 1)
+	pixels := make([]uint8, size*size*4)
+	s := &sunTexture{
+		sprite:   sprites.atlas.add(pixels, size, size),
+		corona:   sprites.atlas.addCanvas(genCorona(n, params.radius, params.color)),
+		radius:   params.radius,
+		grad:     starGradient(params.color),
+		noise:    n,
+		pixels:   pixels,
+		rendered: -1,
+	}
+	s.animate(0)
+	return s
+}
+
+// animate renders the surface at time t (seconds). The surface boils by
+// moving through 3D noise along the Z axis.
+func (s *sunTexture) animate(t float64) {
+	if s.rendered >= 0 && t >= s.rendered && t-s.rendered < sunFrameTime {
+		return
+	}
+	s.rendered = t
+
+	renderSun(s.pixels, s.noise, s.radius, s.grad, t)
+	sprites.atlas.set(s.sprite.Frame(), s.pixels)
+}
+
+// renderSun draws the surface of a star at time t into pixels.
+func renderSun(pixels []uint8, n *noise.Noise, radius float64, grad gradient, t float64) {
 	size := int(radius*2 + 1)
-	canvas = genGradientDisc(radius, 0.98, colornames.White)
-	pixels := canvas.Pixels()
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx := (float64(x) - radius) / radius
+			dy := (float64(y) - radius) / radius
+			d := dx*dx + dy*dy
+			if d > 1 {
+				continue
+			}
+			h := noise.Map(n.FBm3(sunSurface, dx, dy, t*0.1), -0.25, 1.25)
+			// Limb darkening: the edge of a star looks darker.
+			col := grad.at(h).Scaled(0.6 + 0.4*math.Sqrt(1-d))
+
+			index := y*size*4 + x*4
+			pixels[index] = uint8(col.R * 255)
+			pixels[index+1] = uint8(col.G * 255)
+			pixels[index+2] = uint8(col.B * 255)
+			pixels[index+3] = 255
+		}
+	}
+}
+
+// genCorona generates the glow around a star. It is streaky along the
+// angle around the star and reaches up to twice its radius.
+func genCorona(n *noise.Noise, radius float64, c pixel.RGBA) (canvas *pixelgl.Canvas) {
+	outer := radius * 2
+	size := int(outer*2 + 1)
+	canvas = pixelgl.NewCanvas(pixel.R(0, 0, float64(size), float64(size)))
+	canvas.SetPixels(coronaPixels(n, radius, c))
+
+	return
+}
+
+// coronaPixels returns the pixels of the glow generated by genCorona.
+func coronaPixels(n *noise.Noise, radius float64, c pixel.RGBA) (pixels []uint8) {
+	pixels, _ = radialGradientPixels(radialGradient{
+		radius: radius * 2,
+		inner:  radius * 0.9,
+		// The glow falls off quickly.
+		stops: gradient{
+			{0, c},
+			{0.7, c.Scaled(0.05)},
+			{1, pixel.Alpha(0)},
+		},
+		ease: easeSmoothstep,
+		// Sample the noise on a circle so the streaks wrap around.
+		stretch: func(dir pixel.Vec) float64 {
+			return noise.Map(n.FBm2(coronaStreaks, dir.X*8, dir.Y*8), 0.6, 1)
+		},
+	})
 
-	freq := radius / (1000 * (radius / 40) * (radius / 40))
+	return
+}
+
+// genRing generates a flat ring system around a planet sprite of the given
+// radius. inner and outer are relative to the radius. The bands come from
+// 1D noise along the distance to the center. The ring is split into the
+// half behind the planet (upper half) and the half in front of it.
+func genRing(n *noise.Noise, radius, inner, outer float64, tint pixel.RGBA) (back, front *pixelgl.Canvas) {
+	size := int(radius*outer*2 + 1)
+	back = pixelgl.NewCanvas(pixel.R(0, 0, float64(size), float64(size)))
+	front = pixelgl.NewCanvas(pixel.R(0, 0, float64(size), float64(size)))
+	backPixels, frontPixels := ringPixels(n, radius, inner, outer, tint)
+	back.SetPixels(backPixels)
+	front.SetPixels(frontPixels)
+
+	return
+}
+
+// ringPixels returns the pixels of both halves of the ring generated by
+// genRing.
+func ringPixels(n *noise.Noise, radius, inner, outer float64, tint pixel.RGBA) (backPixels, frontPixels []uint8) {
+	r := radius * outer
+	size := int(r*2 + 1)
+	backPixels, frontPixels = make([]uint8, size*size*4), make([]uint8, size*size*4)
+	// Use a different line through the noise for each seed so rings differ.
+	// It is derived from the seed to make the ring reproducible.
+	offset := rand.New(rand.NewSource(n.Seed())).Float64() * 1000
 
 	for y := 0; y < size; y++ {
-		nn := layerNoise(16, 0, float64(y), 0.5, freq, 0.25, 1)
 		for x := 0; x < size; x++ {
+			dist := pixel.V(float64(x)-r, float64(y)-r).Len() / radius
+			if dist < inner || dist > outer {
+				continue
+			}
+			band := noise.Map(n.FBm2(ringBands, dist*radius+offset, offset), -0.5, 1.2)
+			// Fade the ring in and out at its edges.
+			edge := smoothstep(inner, inner+0.05, dist) * (1 - smoothstep(outer-0.1, outer, dist))
+			a := math.Min(1, math.Max(0, band)) * edge * 0.8
+			col := tint.Scaled(a)
+
+			pixels := frontPixels
+			if float64(y) >= r {
+				pixels = backPixels
+			}
 			index := y*size*4 + x*4
-			r, g, b, a := float64(pixels[index]), float64(pixels[index+1]), float64(pixels[index+2]), float64(pixels[index+3])
+			pixels[index] = uint8(col.R * 255)
+			pixels[index+1] = uint8(col.G * 255)
+			pixels[index+2] = uint8(col.B * 255)
+			pixels[index+3] = uint8(col.A * 255)
+		}
+	}
+
+	return
+}
+
+// planetSpec describes the texture of a planet. Equal specs always result
+// in equal textures.
+type planetSpec struct {
+	seed   int64
+	radius float64
+	biome  biome
+}
+
+// surfaceSize returns the size of the equirectangular surface map.
+func (spec planetSpec) surfaceSize() (width, height int) {
+	size := int(spec.radius*2 + 1)
+	return size * 2, size
+}
+
+// planetTexture is the surface of a planet projected onto a sphere. The
+// surface is stored as an equirectangular map (longitude x latitude) so
+// the planet can rotate around its axis by shifting the longitude.
+type planetTexture struct {
+	sprite *pixel.Sprite
+	biome  biome
+
+	surface       []pixel.RGBA
+	width, height int
+
+	// For every pixel of the sprite the column (longitude) and row
+	// (latitude) of the surface it shows and how much it is shaded.
+	// Pixels outside of the disc have a shade of 0.
+	lon, lat []int
+	shade    []float64
+
+	pixels []uint8
+	offset int
+}
+
+// genPlanetSurface generates the surface map of a planet. It is colored by
+// sampling 3D noise on a sphere and mapping it to the biome's gradient.
+// This is the expensive part of generating a planet. It does not touch any
+// canvas and can be used from any goroutine.
+func genPlanetSurface(spec planetSpec) []pixel.RGBA {
+	n := noise.New(spec.seed)
+	width, height := spec.surfaceSize()
+	grad := biomeGradients[spec.biome]
 
-			if a > 0 {
-				nnn := layerNoise(16, float64(x), float64(y), 0.5, freq, 0, 1)
-				n := (nnn + nn) / 2
+	surface := make([]pixel.RGBA, width*height)
+	for v := 0; v < height; v++ {
+		lat := (float64(v)+0.5)/float64(height)*math.Pi - math.Pi/2
+		for u := 0; u < width; u++ {
+			lon := (float64(u)+0.5)/float64(width)*2*math.Pi - math.Pi
+			x, y, z := math.Cos(lat)*math.Sin(lon), math.Sin(lat), math.Cos(lat)*math.Cos(lon)
 
-				pixels[index] = brighten(uint8(r*n), 1.5)
-				pixels[index+1] = brighten(uint8(g*n), 1.5)
-				pixels[index+2] = brighten(uint8(b*n), 1.5)
-				pixels[index+3] = 255 // Make the planet opaque
+			var h float64
+			if spec.biome == biomeGasGiant {
+				// Gas giants consist of latitude bands which are
+				// only slightly disturbed.
+				h = noise.Map(n.FBm3(gasGiantBands, x*0.2, y*4, z*0.2), 0, 1)
+			} else {
+				h = noise.Map(n.FBm3(planetTerrain, x, y, z), -0.25, 1.25)
 			}
+			surface[v*width+u] = grad.at(h)
 		}
 	}
+	return surface
+}
 
-	canvas.SetPixels(pixels)
+// newPlanetTexture projects a surface generated by genPlanetSurface onto a
+// sphere and renders it into a new sprite in the sprite atlas.
+func newPlanetTexture(spec planetSpec, surface []pixel.RGBA) *planetTexture {
+	t := projectPlanet(spec, surface)
+	size := int(spec.radius*2 + 1)
+	t.sprite = sprites.atlas.add(t.pixels, size, size)
 
-	return
+	return t
+}
+
+// projectPlanet projects a surface onto a sphere and paints the pixels of
+// the planet, without creating a sprite.
+func projectPlanet(spec planetSpec, surface []pixel.RGBA) *planetTexture {
+	radius := spec.radius
+	size := int(radius*2 + 1)
+	pixels := gradientDisc(radius, 0.98, colornames.White)
+
+	t := &planetTexture{
+		biome:   spec.biome,
+		surface: surface,
+		lon:     make([]int, size*size),
+		lat:     make([]int, size*size),
+		shade:   make([]float64, size*size),
+		pixels:  pixels,
+	}
+	t.width, t.height = spec.surfaceSize()
+
+	// Project every pixel of the disc onto the visible hemisphere.
+	for py := 0; py < size; py++ {
+		for px := 0; px < size; px++ {
+			index := py*size + px
+			if pixels[index*4+3] == 0 {
+				continue
+			}
+			dx := (float64(px) - radius) / radius
+			dy := (float64(py) - radius) / radius
+			d := math.Min(1, dx*dx+dy*dy)
+			dz := math.Sqrt(1 - d)
+			lat := math.Asin(math.Max(-1, math.Min(1, dy)))
+			lon := math.Atan2(dx, dz)
+
+			t.lat[index] = int(math.Min(float64(t.height-1), (lat+math.Pi/2)/math.Pi*float64(t.height)))
+			t.lon[index] = int((lon+math.Pi)/(2*math.Pi)*float64(t.width)) % t.width
+			// The white disc darkens towards the edge. Keep that as shading.
+			t.shade[index] = float64(pixels[index*4]) / 255
+		}
+	}
+
+	t.paint()
+
+	return t
+}
+
+// render draws the visible hemisphere of the surface into the sprite.
+func (t *planetTexture) render() {
+	t.paint()
+	sprites.atlas.set(t.sprite.Frame(), t.pixels)
+}
+
+// paint draws the visible hemisphere of the surface into the pixels.
+func (t *planetTexture) paint() {
+	for i, shade := range t.shade {
+		if shade == 0 {
+			continue
+		}
+		col := t.surface[t.lat[i]*t.width+(t.lon[i]+t.offset)%t.width].Scaled(shade)
+		t.pixels[i*4] = uint8(col.R * 255)
+		t.pixels[i*4+1] = uint8(col.G * 255)
+		t.pixels[i*4+2] = uint8(col.B * 255)
+		t.pixels[i*4+3] = 255 // Make the planet opaque
+	}
+}
+
+// rotate turns the planet around its axis to angle (rad). The sprite is
+// only redrawn if the rotation moved the surface by at least one column.
+func (t *planetTexture) rotate(angle float64) {
+	offset := int(angle/(2*math.Pi)*float64(t.width)) % t.width
+	if offset < 0 {
+		offset += t.width
+	}
+	if offset == t.offset {
+		return
+	}
+	t.offset = offset
+	t.render()
 }
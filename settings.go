@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// settings are the user preferences that are persisted to disk. They
+// mirror the globals they are applied to.
+type settings struct {
+	Width         int               `json:"width"`
+	Height        int               `json:"height"`
+	Display       displayMode       `json:"display"`
+	Monitor       int               `json:"monitor"`
+	VSync         bool              `json:"vsync"`
+	FPS           int               `json:"fps"`
+	UIScale       float64           `json:"uiScale"`
+	Font          string            `json:"font,omitempty"`
+	Colorblind    bool              `json:"colorblind"`
+	RotatePlanets bool              `json:"rotatePlanets"`
+	KeplerOrbits  bool              `json:"keplerOrbits"`
+	ShowOrbits    bool              `json:"showOrbits"`
+	MusicVolume   float64           `json:"musicVolume"`
+	EffectsVolume float64           `json:"effectsVolume"`
+	Keys          map[string]string `json:"keys"`
+}
+
+// Limits of the settings. The settings file can be edited by hand, so
+// values outside of them are replaced by the defaults when loading.
+const (
+	minScreenWidth  = 320
+	minScreenHeight = 240
+	minUIScale      = 0.5
+	maxUIScale      = 2.0
+	maxFPS          = 240
+)
+
+// settingsPath returns the location of the settings file in the user's
+// config directory.
+func settingsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gonk", "settings.json"), nil
+}
+
+// currentSettings collects the settings from the globals.
+func currentSettings() settings {
+	s := settings{
+		Width:         screenWidth,
+		Height:        screenHeight,
+		Display:       display,
+		Monitor:       monitorIndex,
+		VSync:         vsync,
+		FPS:           fpsLimit,
+		UIScale:       uiScale,
+		Font:          fontPath,
+		Colorblind:    colorblind,
+		RotatePlanets: rotatePlanets,
+		KeplerOrbits:  keplerOrbits,
+		ShowOrbits:    showOrbits,
+		MusicVolume:   musicVolume,
+		EffectsVolume: effectsVolume,
+		Keys:          map[string]string{},
+	}
+	for a, b := range keys {
+		s.Keys[actionNames[a]] = b.String()
+	}
+	return s
+}
+
+// apply sets the globals to the settings. Before the window exists this
+// only sets the values which are then picked up by the init functions.
+// Afterwards everything that depends on changed values is rebuilt. If the
+// display settings can't be applied the previous ones are kept and the
+// error is returned after applying the rest.
+func (s settings) apply() error {
+	old := currentSettings()
+
+	uiScale = s.UIScale
+	fontPath = s.Font
+	colorblind = s.Colorblind
+	rotatePlanets = s.RotatePlanets
+	keplerOrbits = s.KeplerOrbits
+	showOrbits = s.ShowOrbits
+	musicVolume = s.MusicVolume
+	effectsVolume = s.EffectsVolume
+	for a, name := range actionNames {
+		if b, ok := buttonByName(s.Keys[name]); ok {
+			keys[a] = b
+		}
+	}
+	setFPS(s.FPS)
+
+	if window == nil {
+		screenWidth, screenHeight = s.Width, s.Height
+		display = s.Display
+		monitorIndex = s.Monitor
+		vsync = s.VSync
+		return nil
+	}
+
+	for i := range players {
+		players[i].color = playerColor(i)
+	}
+	var err error
+	if s.Width != old.Width || s.Height != old.Height || s.Display != old.Display || s.Monitor != old.Monitor || s.VSync != old.VSync {
+		err = setDisplay(s.Display, s.Monitor, s.Width, s.Height, s.VSync)
+	}
+	if s.Font != old.Font {
+		initFonts()
+		handleResize()
+	} else if s.UIScale != old.UIScale {
+		setUIScale(s.UIScale)
+		handleResize()
+	}
+	return err
+}
+
+// validate replaces all values of s that are out of range by the values
+// of defaults.
+func (s *settings) validate(defaults settings) {
+	if s.Width < minScreenWidth || s.Height < minScreenHeight {
+		s.Width, s.Height = defaults.Width, defaults.Height
+	}
+	if s.Display < displayWindowed || s.Display > displayBorderless {
+		s.Display = defaults.Display
+	}
+	if s.FPS < 0 || s.FPS > maxFPS {
+		s.FPS = defaults.FPS
+	}
+	if !(s.UIScale >= minUIScale && s.UIScale <= maxUIScale) {
+		s.UIScale = defaults.UIScale
+	}
+	if !(s.MusicVolume >= 0 && s.MusicVolume <= 1) {
+		s.MusicVolume = defaults.MusicVolume
+	}
+	if !(s.EffectsVolume >= 0 && s.EffectsVolume <= 1) {
+		s.EffectsVolume = defaults.EffectsVolume
+	}
+}
+
+// loadSettings reads the settings file and applies it. A missing file
+// is not an error, the defaults are kept in that case. Invalid values
+// are replaced by the defaults.
+func loadSettings() error {
+	path, err := settingsPath()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// Start from the current values so missing fields keep their defaults.
+	defaults := currentSettings()
+	s := defaults
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	s.validate(defaults)
+	return s.apply()
+}
+
+// saveSettings writes the current settings to the settings file. The file
+// is replaced atomically so a crash can never leave a broken file behind.
+func saveSettings() error {
+	path, err := settingsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(currentSettings(), "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, data)
+}
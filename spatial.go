@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+// gridCell is the index of a cell of a spatialGrid.
+type gridCell struct {
+	x, y int
+}
+
+// spatialEntry is a star, planet or ship in a spatialGrid. Exactly one
+// of the pointers is set.
+type spatialEntry struct {
+	pos    pixel.Vec
+	radius float64
+	star   *star
+	planet *planet
+	ship   *ship
+}
+
+// spatialGrid is a uniform grid of entities for finding the ones in an
+// area without looking at every entity. Queries only look at the cells
+// overlapping the area. Entities are stored in the cell of their center,
+// so queries are widened by the largest radius.
+type spatialGrid struct {
+	size      float64
+	maxRadius float64
+	cells     map[gridCell][]spatialEntry
+}
+
+// newSpatialGrid creates a grid with square cells of the given size.
+// Queries are fastest if the size is about the query radius.
+func newSpatialGrid(size float64) *spatialGrid {
+	return &spatialGrid{size: size, cells: map[gridCell][]spatialEntry{}}
+}
+
+func (g *spatialGrid) cell(pos pixel.Vec) gridCell {
+	return gridCell{int(math.Floor(pos.X / g.size)), int(math.Floor(pos.Y / g.size))}
+}
+
+// clear removes all entities. The memory of the cells is kept for reuse.
+func (g *spatialGrid) clear() {
+	for c, entries := range g.cells {
+		if len(entries) == 0 {
+			delete(g.cells, c)
+			continue
+		}
+		g.cells[c] = entries[:0]
+	}
+	g.maxRadius = 0
+}
+
+func (g *spatialGrid) insert(e spatialEntry) {
+	c := g.cell(e.pos)
+	g.cells[c] = append(g.cells[c], e)
+	g.maxRadius = math.Max(g.maxRadius, e.radius)
+}
+
+func (g *spatialGrid) insertStar(s *star) {
+	g.insert(spatialEntry{pos: s.pos, radius: s.radius, star: s})
+}
+
+func (g *spatialGrid) insertPlanet(p *planet) {
+	g.insert(spatialEntry{pos: p.pos, radius: p.radius, planet: p})
+}
+
+func (g *spatialGrid) insertShip(s *ship) {
+	g.insert(spatialEntry{pos: s.pos, ship: s})
+}
+
+// each calls fn for every entity in the cells overlapping r until fn
+// returns false.
+func (g *spatialGrid) each(r pixel.Rect, fn func(spatialEntry) bool) {
+	min := g.cell(r.Min.Sub(pixel.V(g.maxRadius, g.maxRadius)))
+	max := g.cell(r.Max.Add(pixel.V(g.maxRadius, g.maxRadius)))
+	for x := min.x; x <= max.x; x++ {
+		for y := min.y; y <= max.y; y++ {
+			for _, e := range g.cells[gridCell{x, y}] {
+				if !fn(e) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// near calls fn for every entity touching the circle around pos until fn
+// returns false.
+func (g *spatialGrid) near(pos pixel.Vec, radius float64, fn func(spatialEntry) bool) {
+	r := pixel.R(pos.X-radius, pos.Y-radius, pos.X+radius, pos.Y+radius)
+	g.each(r, func(e spatialEntry) bool {
+		if e.pos.Sub(pos).Len() > radius+e.radius {
+			return true
+		}
+		return fn(e)
+	})
+}
+
+// inRect calls fn for every entity touching r until fn returns false.
+func (g *spatialGrid) inRect(r pixel.Rect, fn func(spatialEntry) bool) {
+	g.each(r, func(e spatialEntry) bool {
+		// The closest point of r to the entity.
+		closest := pixel.V(
+			math.Max(r.Min.X, math.Min(r.Max.X, e.pos.X)),
+			math.Max(r.Min.Y, math.Min(r.Max.Y, e.pos.Y)),
+		)
+		if closest.Sub(e.pos).Len() > e.radius {
+			return true
+		}
+		return fn(e)
+	})
+}
+
+// updateIndex rebuilds the spatial index of the world from the current
+// positions of all stars, planets and ships.
+func updateIndex() {
+	bodyGrid.clear()
+	shipGrid.clear()
+	maxBodySpeed = 0
+	for _, s := range stars {
+		bodyGrid.insertStar(s)
+		maxBodySpeed = math.Max(maxBodySpeed, s.velocity(gameTime).Len())
+	}
+	for _, p := range planets {
+		bodyGrid.insertPlanet(p)
+		maxBodySpeed = math.Max(maxBodySpeed, p.velocity(gameTime).Len())
+		for _, s := range p.ships {
+			shipGrid.insertShip(s)
+		}
+	}
+	for _, f := range fleets {
+		for _, s := range f.ships {
+			shipGrid.insertShip(s)
+		}
+	}
+}
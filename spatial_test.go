@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/faiface/pixel"
+)
+
+// testGrid returns a grid with cells of size 64 and entities that lie in
+// different cells or straddle cell borders. The entities are told apart
+// by the ship pointers.
+func testGrid() (*spatialGrid, map[*ship]string) {
+	g := newSpatialGrid(64)
+	names := map[*ship]string{}
+	add := func(name string, pos pixel.Vec, radius float64) {
+		s := &ship{}
+		names[s] = name
+		g.insert(spatialEntry{pos: pos, radius: radius, ship: s})
+	}
+	add("big", pixel.V(100, 0), 40)
+	add("border", pixel.V(63.9, 0), 0)
+	add("negative", pixel.V(-0.5, -0.5), 0)
+	add("corner", pixel.V(10, 10), 0)
+	add("far", pixel.V(300, 300), 5)
+	return g, names
+}
+
+func collect(names map[*ship]string, query func(func(spatialEntry) bool)) []string {
+	found := []string{}
+	query(func(e spatialEntry) bool {
+		found = append(found, names[e.ship])
+		return true
+	})
+	sort.Strings(found)
+	return found
+}
+
+func TestSpatialGridNear(t *testing.T) {
+	tests := []struct {
+		pos    pixel.Vec
+		radius float64
+		want   []string
+	}{
+		// Only found because queries are widened by the largest radius.
+		{pixel.V(52, 0), 10, []string{"big"}},
+		{pixel.V(64.1, 0), 1, []string{"big", "border"}},
+		{pixel.V(0.5, 0.5), 2, []string{"negative"}},
+		{pixel.V(0, 0), 5, []string{"negative"}},
+		{pixel.V(200, 200), 50, []string{}},
+		{pixel.V(300, 310), 5, []string{"far"}},
+	}
+	g, names := testGrid()
+	for _, test := range tests {
+		got := collect(names, func(fn func(spatialEntry) bool) {
+			g.near(test.pos, test.radius, fn)
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("near(%v, %v) = %v, want %v", test.pos, test.radius, got, test.want)
+		}
+	}
+}
+
+func TestSpatialGridInRect(t *testing.T) {
+	tests := []struct {
+		rect pixel.Rect
+		want []string
+	}{
+		{pixel.R(0, 0, 10, 10), []string{"corner"}},
+		{pixel.R(0, 0, 9, 9), []string{}},
+		{pixel.R(-1, -1, 0, 0), []string{"negative"}},
+		// The rects do not contain the center of big, only its edge. The
+		// first one is only found because of the widening.
+		{pixel.R(40, -5, 60, 5), []string{"big"}},
+		{pixel.R(60, -5, 70, 5), []string{"big", "border"}},
+		{pixel.R(200, 200, 297, 297), []string{"far"}},
+		{pixel.R(200, 200, 296, 296), []string{}},
+	}
+	g, names := testGrid()
+	for _, test := range tests {
+		got := collect(names, func(fn func(spatialEntry) bool) {
+			g.inRect(test.rect, fn)
+		})
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("inRect(%v) = %v, want %v", test.rect, got, test.want)
+		}
+	}
+}
+
+func TestSpatialGridStop(t *testing.T) {
+	g, _ := testGrid()
+	calls := 0
+	g.inRect(pixel.R(-100, -100, 400, 400), func(spatialEntry) bool {
+		calls++
+		return false
+	})
+	if calls != 1 {
+		t.Errorf("inRect called fn %d times after it returned false, want 1", calls)
+	}
+
+	g.clear()
+	g.near(pixel.V(100, 0), 100, func(spatialEntry) bool {
+		t.Error("near found an entity after clear")
+		return true
+	})
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/faiface/pixel"
+)
+
+const (
+	// spriteCacheVersion is part of every cache key. It has to be
+	// increased whenever a generator changes, so outdated sprites are not
+	// loaded.
+	spriteCacheVersion = 1
+	// spriteCacheLimit is the most bytes the sprite cache may use. The
+	// least recently used sprites are removed once it grows larger.
+	spriteCacheLimit = 64 << 20
+)
+
+// spriteCacheDir returns the directory of the sprite cache in the user's
+// cache directory.
+func spriteCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gonk", "sprites"), nil
+}
+
+// surfaceCachePath returns the cache file of the surface of a planet.
+func surfaceCachePath(spec planetSpec) (string, error) {
+	dir, err := spriteCacheDir()
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("planet-v%d-%d-%d-%g.png", spriteCacheVersion, spec.seed, spec.biome, spec.radius)
+	return filepath.Join(dir, name), nil
+}
+
+// loadSurface reads a cached surface. It fails if the file does not exist
+// or does not have the expected size.
+func loadSurface(path string, width, height int) ([]pixel.RGBA, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	if b := img.Bounds(); b.Dx() != width || b.Dy() != height {
+		return nil, fmt.Errorf("%s has size %dx%d, expected %dx%d", path, b.Dx(), b.Dy(), width, height)
+	}
+
+	surface := make([]pixel.RGBA, width*height)
+	for v := 0; v < height; v++ {
+		for u := 0; u < width; u++ {
+			surface[v*width+u] = pixel.ToRGBA(img.At(img.Bounds().Min.X+u, img.Bounds().Min.Y+v))
+		}
+	}
+	return surface, nil
+}
+
+// saveSurface writes a surface to the cache.
+func saveSurface(path string, surface []pixel.RGBA, width, height int) error {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for v := 0; v < height; v++ {
+		for u := 0; u < width; u++ {
+			c := surface[v*width+u]
+			img.SetRGBA(u, v, color.RGBA{uint8(c.R * 255), uint8(c.G * 255), uint8(c.B * 255), uint8(c.A * 255)})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes())
+}
+
+// cachedPlanetSurface returns the surface for spec from the cache or
+// generates and caches it. Failing to use the cache is not fatal, the
+// surface is generated in that case.
+func cachedPlanetSurface(spec planetSpec) []pixel.RGBA {
+	width, height := spec.surfaceSize()
+	path, err := surfaceCachePath(spec)
+	if err != nil {
+		return genPlanetSurface(spec)
+	}
+	if surface, err := loadSurface(path, width, height); err == nil {
+		// The modification time marks the sprite as recently used.
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return surface
+	}
+
+	surface := genPlanetSurface(spec)
+	if err := saveSurface(path, surface, width, height); err != nil {
+		fmt.Fprintln(os.Stderr, "could not cache planet sprite:", err)
+	}
+	return surface
+}
+
+// pruneSpriteCache removes the least recently used sprites until the
+// cache is not larger than spriteCacheLimit.
+func pruneSpriteCache() error {
+	dir, err := spriteCacheDir()
+	if err != nil {
+		return err
+	}
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().After(files[j].ModTime())
+	})
+	var size int64
+	for _, f := range files {
+		size += f.Size()
+		if size <= spriteCacheLimit {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, f.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// genPlanetTextures creates the textures for all specs. The surfaces are
+// generated on all CPUs in parallel, the canvases are created afterwards.
+func genPlanetTextures(specs []planetSpec) []*planetTexture {
+	surfaces := make([][]pixel.RGBA, len(specs))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				surfaces[i] = cachedPlanetSurface(specs[i])
+			}
+		}()
+	}
+	for i := range specs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if err := pruneSpriteCache(); err != nil {
+		fmt.Fprintln(os.Stderr, "could not clean up sprite cache:", err)
+	}
+
+	textures := make([]*planetTexture, len(specs))
+	for i, spec := range specs {
+		textures[i] = newPlanetTexture(spec, surfaces[i])
+	}
+	return textures
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math/rand"
+
+	"github.com/faiface/pixel"
+)
+
+// starClass determines size and color of a star.
+type starClass int
+
+const (
+	starRedDwarf starClass = iota
+	starYellow
+	starBlueGiant
+	starClassCount
+)
+
+// starClassParams are the properties of all stars of a class.
+type starClassParams struct {
+	name   string
+	radius float64
+	mass   float64
+	color  pixel.RGBA
+}
+
+var starClasses = [starClassCount]starClassParams{
+	starRedDwarf:  {name: "red dwarf", radius: 18, mass: 0.5, color: rgb(255, 90, 50)},
+	starYellow:    {name: "yellow star", radius: 30, mass: 1, color: rgb(255, 210, 60)},
+	starBlueGiant: {name: "blue giant", radius: 45, mass: 3, color: rgb(150, 190, 255)},
+}
+
+func (c starClass) String() string {
+	return starClasses[c].name
+}
+
+// randomStarClass returns a random star class.
+func randomStarClass() starClass {
+	return starClass(rand.Intn(int(starClassCount)))
+}
+
+// systemLayout is the arrangement of the stars of a solar system.
+type systemLayout int
+
+const (
+	// layoutEmpty has no star.
+	layoutEmpty systemLayout = iota
+	layoutSingle
+	// layoutCloseBinary has two stars which the planets orbit together.
+	layoutCloseBinary
+	// layoutWideBinary has two stars far apart with their own planets.
+	layoutWideBinary
+)
+
+func (l systemLayout) String() string {
+	return [...]string{
+		layoutEmpty:       "empty",
+		layoutSingle:      "single star",
+		layoutCloseBinary: "close binary",
+		layoutWideBinary:  "wide binary",
+	}[l]
+}
+
+// randomLayout returns a random layout. Single stars are the most common.
+func randomLayout() systemLayout {
+	r := rand.Float64()
+	switch {
+	case r < 0.1:
+		return layoutEmpty
+	case r < 0.6:
+		return layoutSingle
+	case r < 0.85:
+		return layoutCloseBinary
+	}
+	return layoutWideBinary
+}
+
+// starGradient returns the gradient the surface noise of a star of the
+// given color is mapped to.
+func starGradient(col pixel.RGBA) gradient {
+	white := pixel.RGB(1, 1, 1)
+	return gradient{
+		{0.3, col.Scaled(0.6)},
+		{0.55, col},
+		{0.8, col.Scaled(0.5).Add(white.Scaled(0.5))},
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+
+	"github.com/faiface/pixel"
+)
+
+const (
+	// obstacleMargin is the distance fleets keep from stars and planets.
+	obstacleMargin = 8.0
+	// avoidHorizon is how far ahead (s) fleets look for obstacles.
+	avoidHorizon = 1.5
+	// avoidWeight is how strongly avoiding an obstacle overrides the
+	// direction to the target.
+	avoidWeight = 2.0
+	// fleetTurnRate is the fastest a fleet can turn (rad/s). It makes
+	// the paths smooth curves.
+	fleetTurnRate = 2.5
+)
+
+// avoid returns the direction to steer in to get away from the most
+// imminent star or planet on the current course or the zero vector if the
+// course is clear. Obstacles that contain pos, like the planet a fleet is
+// leaving, are ignored. So are the target and the bodies it orbits if
+// they are so close that avoiding them would keep fleets from the target,
+// like the planet of a satellite.
+func avoid(pos, vel pixel.Vec, target *planet) pixel.Vec {
+	var push pixel.Vec
+	first := avoidHorizon
+
+	// Only obstacles the fleet can reach within the horizon matter. They
+	// can come closer from any side, so their speed counts as well.
+	reach := (vel.Len()+maxBodySpeed)*avoidHorizon + obstacleMargin
+	bodyGrid.near(pos, reach, func(e spatialEntry) bool {
+		var o *body
+		switch {
+		case e.star != nil:
+			o = &e.star.body
+		case e.planet != nil && e.planet != target:
+			o = &e.planet.body
+		default:
+			return true
+		}
+		radius := e.radius + obstacleMargin
+		if target.orbits(o) && e.pos.Sub(target.pos).Len() < radius+target.radius {
+			return true
+		}
+		rel := e.pos.Sub(pos)
+		if rel.Len() < radius {
+			return true
+		}
+		// Time of the closest approach if both keep their velocity.
+		relVel := o.velocity(gameTime).Sub(vel)
+		t := 0.0
+		if s := relVel.Dot(relVel); s > 0 {
+			t = math.Max(0, -rel.Dot(relVel)/s)
+		}
+		if t >= first {
+			return true
+		}
+		closest := rel.Add(relVel.Scaled(t))
+		d := closest.Len()
+		if d >= radius {
+			return true
+		}
+
+		first = t
+		// Steer away from the obstacle's center at the closest approach.
+		// A head-on course has no side to prefer, so pick one.
+		away := closest.Scaled(-1)
+		if d == 0 {
+			away = vel.Normal()
+		}
+		push = away.Unit().Scaled((radius - d) / radius)
+		return true
+	})
+
+	return push
+}
+
+// steer turns vel towards desired by at most maxTurn (rad) and keeps its
+// speed. A zero vel turns instantly.
+func steer(vel, desired pixel.Vec, maxTurn float64) pixel.Vec {
+	if vel == pixel.ZV {
+		return desired
+	}
+	turn := math.Remainder(desired.Angle()-vel.Angle(), 2*math.Pi)
+	turn = math.Max(-maxTurn, math.Min(maxTurn, turn))
+	return vel.Rotated(turn)
+}
@@ -0,0 +1,697 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/faiface/pixel"
+	"github.com/faiface/pixel/imdraw"
+	"github.com/faiface/pixel/pixelgl"
+	"github.com/faiface/pixel/text"
+	"golang.org/x/image/colornames"
+)
+
+// The colors used by all widgets.
+var (
+	uiBackground = pixel.RGBA{R: 0.05, G: 0.05, B: 0.1, A: 0.85}
+	uiBorder     = pixel.ToRGBA(colornames.Slategray)
+	uiText       = pixel.ToRGBA(colornames.Antiquewhite)
+	uiDisabled   = pixel.ToRGBA(colornames.Dimgray)
+	uiHover      = pixel.RGBA{R: 0.2, G: 0.25, B: 0.35, A: 1}
+	uiFocus      = pixel.ToRGBA(colornames.Skyblue)
+
+	uiPadding = 8.0
+)
+
+// widget is a single element of the user interface. Widgets are retained:
+// they are created once, placed by the panel they are added to and then
+// updated and drawn every frame by the ui they belong to.
+type widget interface {
+	base() *widgetBase
+	// focusable reports whether the widget can receive keyboard focus.
+	focusable() bool
+	// handle processes input. It is called for the focused widget only.
+	handle(u *ui)
+	draw(u *ui)
+}
+
+// widgetBase holds the state that is common to all widgets.
+type widgetBase struct {
+	rect     pixel.Rect
+	hovered  bool
+	focused  bool
+	disabled bool
+}
+
+func (b *widgetBase) base() *widgetBase {
+	return b
+}
+
+// ui manages a set of panels and renders them into its own canvas so it is
+// independent of the world camera.
+type ui struct {
+	canvas *pixelgl.Canvas
+	imd    *imdraw.IMDraw
+	atlas  *text.Atlas
+	// One text per atlas that is in use.
+	texts map[*text.Atlas]*text.Text
+
+	panels []*panel
+	focus  widget
+	// onBack is called when escape is pressed.
+	onBack func()
+
+	// Input state of the current frame.
+	win     *pixelgl.Window
+	mouse   pixel.Vec
+	clicked bool
+}
+
+func newUI(bounds pixel.Rect, atlas *text.Atlas) *ui {
+	return &ui{
+		canvas: pixelgl.NewCanvas(bounds),
+		imd:    imdraw.New(nil),
+		atlas:  atlas,
+		texts:  map[*text.Atlas]*text.Text{},
+	}
+}
+
+// add adds a top level panel to the ui.
+func (u *ui) add(p *panel) *panel {
+	u.panels = append(u.panels, p)
+	return p
+}
+
+// walk calls fn for all widgets in drawing order. Panels come before their
+// children.
+func (u *ui) walk(fn func(w widget)) {
+	for _, p := range u.panels {
+		p.walk(fn)
+	}
+}
+
+// focusables returns all widgets that can currently receive focus.
+func (u *ui) focusables() (ws []widget) {
+	u.walk(func(w widget) {
+		if w.focusable() && !w.base().disabled {
+			ws = append(ws, w)
+		}
+	})
+	return
+}
+
+// setFocus moves the keyboard focus to w. Passing nil removes the focus.
+// A key input that loses the focus stops capturing.
+func (u *ui) setFocus(w widget) {
+	if k, ok := u.focus.(*keyInput); ok && w != u.focus {
+		k.capturing = false
+	}
+	if u.focus != nil {
+		u.focus.base().focused = false
+	}
+	u.focus = w
+	if w != nil {
+		w.base().focused = true
+	}
+}
+
+// cycleFocus moves the focus forward (dir = 1) or backward (dir = -1).
+func (u *ui) cycleFocus(dir int) {
+	ws := u.focusables()
+	if len(ws) == 0 {
+		return
+	}
+	i := -1
+	for j, w := range ws {
+		if w == u.focus {
+			i = j
+			break
+		}
+	}
+	if i < 0 && dir < 0 {
+		i = 0
+	}
+	u.setFocus(ws[(i+dir+len(ws))%len(ws)])
+}
+
+// update processes input for all widgets. It has to be called once per frame.
+func (u *ui) update(win *pixelgl.Window) {
+	u.win = win
+	u.mouse = win.MousePosition()
+	u.clicked = win.JustPressed(pixelgl.MouseButtonLeft)
+
+	var top widget
+	u.walk(func(w widget) {
+		b := w.base()
+		b.hovered = !b.disabled && b.rect.Contains(u.mouse)
+		if b.hovered && w.focusable() {
+			top = w
+		}
+	})
+
+	if win.JustPressed(pixelgl.KeyTab) || win.Repeated(pixelgl.KeyTab) {
+		if win.Pressed(pixelgl.KeyLeftShift) || win.Pressed(pixelgl.KeyRightShift) {
+			u.cycleFocus(-1)
+		} else {
+			u.cycleFocus(1)
+		}
+	}
+	if u.clicked {
+		u.setFocus(top)
+	}
+	if !u.capturing() && win.JustPressed(pixelgl.KeyEscape) && u.onBack != nil {
+		u.onBack()
+		return
+	}
+	if u.focus != nil && !u.focus.base().disabled {
+		u.focus.handle(u)
+	}
+}
+
+// capturing reports whether the focused widget currently grabs all keys.
+func (u *ui) capturing() bool {
+	k, ok := u.focus.(*keyInput)
+	return ok && k.capturing
+}
+
+// draw renders all widgets into the ui's canvas and draws the canvas to t.
+func (u *ui) draw(t pixel.Target) {
+	u.canvas.Clear(pixel.Alpha(0))
+	u.imd.Clear()
+	for _, txt := range u.texts {
+		txt.Clear()
+	}
+
+	u.walk(func(w widget) {
+		w.draw(u)
+	})
+
+	u.imd.Draw(u.canvas)
+	for _, txt := range u.texts {
+		txt.Draw(u.canvas, pixel.IM)
+	}
+	u.canvas.Draw(t, pixel.IM.Moved(u.canvas.Bounds().Center()))
+}
+
+// activated reports whether the focused widget was triggered this frame,
+// either by clicking it or by pressing enter or space.
+func (u *ui) activated(w widget) bool {
+	if u.clicked && w.base().hovered {
+		return true
+	}
+	return u.win.JustPressed(pixelgl.KeyEnter) || u.win.JustPressed(pixelgl.KeySpace)
+}
+
+// rect draws a filled rectangle and, if border is not nil, an outline.
+func (u *ui) rect(r pixel.Rect, fill, border color.Color) {
+	if fill != nil {
+		u.imd.Color = fill
+		u.imd.Push(r.Min, r.Max)
+		u.imd.Rectangle(0)
+	}
+	if border != nil {
+		u.imd.Color = border
+		u.imd.Push(r.Min, r.Max)
+		u.imd.Rectangle(1)
+	}
+}
+
+// text returns the text used for writing with atlas. A nil atlas selects
+// the ui's default atlas.
+func (u *ui) text(atlas *text.Atlas) *text.Text {
+	if atlas == nil {
+		atlas = u.atlas
+	}
+	txt, ok := u.texts[atlas]
+	if !ok {
+		txt = text.New(pixel.ZV, atlas)
+		u.texts[atlas] = txt
+	}
+	return txt
+}
+
+// textWidth returns the width of s when written with atlas.
+func textWidth(atlas *text.Atlas, s string) (w float64) {
+	for _, r := range s {
+		w += atlas.Glyph(r).Advance
+	}
+	return
+}
+
+// label writes s vertically centered into r using the ui's default atlas.
+// If center is set s is also centered horizontally, otherwise it is left
+// aligned.
+func (u *ui) label(s string, r pixel.Rect, center bool, col color.Color) {
+	u.labelAtlas(nil, s, r, center, col)
+}
+
+// labelAtlas is like label but writes with the given atlas.
+func (u *ui) labelAtlas(atlas *text.Atlas, s string, r pixel.Rect, center bool, col color.Color) {
+	txt := u.text(atlas)
+	atlas = txt.Atlas()
+	dot := pixel.V(r.Min.X+uiPadding, r.Center().Y-(atlas.Ascent()-atlas.Descent())/2)
+	if center {
+		dot.X = r.Center().X - textWidth(atlas, s)/2
+	}
+	txt.Color = col
+	txt.Dot = dot
+	txt.WriteString(s)
+}
+
+// widgetColors returns the fill and border colors of a widget depending on
+// its state.
+func widgetColors(b *widgetBase) (fill, border color.Color) {
+	fill, border = uiBackground, uiBorder
+	if b.hovered {
+		fill = uiHover
+	}
+	if b.focused {
+		border = uiFocus
+	}
+	return
+}
+
+// textColor returns the color of a widget's text depending on its state.
+func textColor(b *widgetBase) color.Color {
+	if b.disabled {
+		return uiDisabled
+	}
+	return uiText
+}
+
+// panel is a container that stacks its children vertically.
+type panel struct {
+	widgetBase
+	children []widget
+	// The top edge of the next child.
+	cursor float64
+	// Whether the panel draws a background.
+	visible bool
+}
+
+func newPanel(rect pixel.Rect, visible bool) *panel {
+	return &panel{
+		widgetBase: widgetBase{rect: rect},
+		cursor:     rect.Max.Y - uiPadding,
+		visible:    visible,
+	}
+}
+
+// add places w below the previously added child, spanning the full inner
+// width of the panel.
+func (p *panel) add(w widget, height float64) widget {
+	w.base().rect = pixel.R(p.rect.Min.X+uiPadding, p.cursor-height, p.rect.Max.X-uiPadding, p.cursor)
+	if sub, ok := w.(*panel); ok {
+		sub.cursor = p.cursor - uiPadding
+	}
+	p.cursor -= height + uiPadding
+	p.children = append(p.children, w)
+	return w
+}
+
+func (p *panel) walk(fn func(w widget)) {
+	fn(p)
+	for _, c := range p.children {
+		if sub, ok := c.(*panel); ok {
+			sub.walk(fn)
+		} else {
+			fn(c)
+		}
+	}
+}
+
+func (p *panel) focusable() bool {
+	return false
+}
+
+func (p *panel) handle(u *ui) {}
+
+func (p *panel) draw(u *ui) {
+	if p.visible {
+		u.rect(p.rect, uiBackground, uiBorder)
+	}
+}
+
+// label is a widget that just displays text. If atlas is nil the ui's
+// default atlas is used.
+type label struct {
+	widgetBase
+	text   string
+	center bool
+	atlas  *text.Atlas
+}
+
+func newLabel(text string, center bool) *label {
+	return &label{text: text, center: center}
+}
+
+func (l *label) focusable() bool {
+	return false
+}
+
+func (l *label) handle(u *ui) {}
+
+func (l *label) draw(u *ui) {
+	u.labelAtlas(l.atlas, l.text, l.rect, l.center, textColor(&l.widgetBase))
+}
+
+// button calls onClick when it is activated.
+type button struct {
+	widgetBase
+	text    string
+	onClick func()
+}
+
+func newButton(text string, onClick func()) *button {
+	return &button{text: text, onClick: onClick}
+}
+
+func (b *button) focusable() bool {
+	return true
+}
+
+func (b *button) handle(u *ui) {
+	if u.activated(b) && b.onClick != nil {
+		b.onClick()
+	}
+}
+
+func (b *button) draw(u *ui) {
+	fill, border := widgetColors(&b.widgetBase)
+	u.rect(b.rect, fill, border)
+	u.label(b.text, b.rect, true, textColor(&b.widgetBase))
+}
+
+// checkbox toggles the bool it points to when it is activated.
+type checkbox struct {
+	widgetBase
+	text     string
+	value    *bool
+	onChange func()
+}
+
+func newCheckbox(text string, value *bool) *checkbox {
+	return &checkbox{text: text, value: value}
+}
+
+func (c *checkbox) focusable() bool {
+	return true
+}
+
+func (c *checkbox) handle(u *ui) {
+	if u.activated(c) {
+		*c.value = !*c.value
+		if c.onChange != nil {
+			c.onChange()
+		}
+	}
+}
+
+func (c *checkbox) draw(u *ui) {
+	fill, border := widgetColors(&c.widgetBase)
+	size := c.rect.H() - 2*uiPadding
+	box := pixel.R(c.rect.Min.X+uiPadding, c.rect.Min.Y+uiPadding, c.rect.Min.X+uiPadding+size, c.rect.Max.Y-uiPadding)
+	u.rect(c.rect, fill, border)
+	u.rect(box, nil, textColor(&c.widgetBase))
+	if *c.value {
+		u.rect(pixel.R(box.Min.X+3, box.Min.Y+3, box.Max.X-3, box.Max.Y-3), textColor(&c.widgetBase), nil)
+	}
+	r := c.rect
+	r.Min.X = box.Max.X
+	u.label(c.text, r, false, textColor(&c.widgetBase))
+}
+
+// slider sets the float64 it points to to a value between min and max.
+// It can be dragged with the mouse or moved in steps with the arrow keys.
+// If valueText is set the current value is shown next to the text.
+type slider struct {
+	widgetBase
+	text      string
+	value     *float64
+	min, max  float64
+	step      float64
+	valueText func(v float64) string
+	onChange  func()
+}
+
+func newSlider(text string, value *float64, min, max, step float64) *slider {
+	return &slider{text: text, value: value, min: min, max: max, step: step}
+}
+
+func (s *slider) focusable() bool {
+	return true
+}
+
+// track returns the rectangle in which the slider's knob moves.
+func (s *slider) track() pixel.Rect {
+	return pixel.R(s.rect.Center().X, s.rect.Min.Y+uiPadding, s.rect.Max.X-uiPadding, s.rect.Max.Y-uiPadding)
+}
+
+func (s *slider) set(v float64) {
+	v = math.Min(s.max, math.Max(s.min, v))
+	if v == *s.value {
+		return
+	}
+	*s.value = v
+	if s.onChange != nil {
+		s.onChange()
+	}
+}
+
+func (s *slider) handle(u *ui) {
+	if u.win.Pressed(pixelgl.MouseButtonLeft) && s.hovered {
+		t := s.track()
+		v := s.min + (u.mouse.X-t.Min.X)/t.W()*(s.max-s.min)
+		if s.step > 0 {
+			v = s.min + math.Round((v-s.min)/s.step)*s.step
+		}
+		s.set(v)
+	}
+	if u.win.JustPressed(pixelgl.KeyLeft) || u.win.Repeated(pixelgl.KeyLeft) {
+		s.set(*s.value - s.step)
+	}
+	if u.win.JustPressed(pixelgl.KeyRight) || u.win.Repeated(pixelgl.KeyRight) {
+		s.set(*s.value + s.step)
+	}
+}
+
+func (s *slider) draw(u *ui) {
+	fill, border := widgetColors(&s.widgetBase)
+	u.rect(s.rect, fill, border)
+	label := s.text
+	if s.valueText != nil {
+		label += ": " + s.valueText(*s.value)
+	}
+	u.label(label, s.rect, false, textColor(&s.widgetBase))
+
+	t := s.track()
+	u.imd.Color = uiBorder
+	u.imd.Push(pixel.V(t.Min.X, t.Center().Y), pixel.V(t.Max.X, t.Center().Y))
+	u.imd.Line(2)
+
+	x := t.Min.X + (*s.value-s.min)/(s.max-s.min)*t.W()
+	u.rect(pixel.R(x-3, t.Min.Y, x+3, t.Max.Y), textColor(&s.widgetBase), nil)
+}
+
+// textInput edits the string it points to.
+type textInput struct {
+	widgetBase
+	value    *string
+	maxLen   int
+	onChange func()
+}
+
+func newTextInput(value *string, maxLen int) *textInput {
+	return &textInput{value: value, maxLen: maxLen}
+}
+
+func (t *textInput) focusable() bool {
+	return true
+}
+
+func (t *textInput) handle(u *ui) {
+	old := *t.value
+	runes := []rune(*t.value)
+	for _, r := range u.win.Typed() {
+		if len(runes) < t.maxLen && u.atlas.Contains(r) {
+			runes = append(runes, r)
+		}
+	}
+	if (u.win.JustPressed(pixelgl.KeyBackspace) || u.win.Repeated(pixelgl.KeyBackspace)) && len(runes) > 0 {
+		runes = runes[:len(runes)-1]
+	}
+	*t.value = string(runes)
+	if *t.value != old && t.onChange != nil {
+		t.onChange()
+	}
+}
+
+func (t *textInput) draw(u *ui) {
+	fill, border := widgetColors(&t.widgetBase)
+	u.rect(t.rect, fill, border)
+	s := *t.value
+	if t.focused {
+		s += "_"
+	}
+	u.label(s, t.rect, false, textColor(&t.widgetBase))
+}
+
+// list shows a number of items of which one can be selected. It does not
+// scroll, so its height has to fit all items.
+type list struct {
+	widgetBase
+	items    []string
+	selected *int
+	onChange func()
+}
+
+func newList(items []string, selected *int) *list {
+	return &list{items: items, selected: selected}
+}
+
+func (l *list) focusable() bool {
+	return true
+}
+
+// itemRect returns the rectangle of the i-th item.
+func (l *list) itemRect(i int) pixel.Rect {
+	h := l.rect.H() / float64(len(l.items))
+	top := l.rect.Max.Y - float64(i)*h
+	return pixel.R(l.rect.Min.X, top-h, l.rect.Max.X, top)
+}
+
+func (l *list) selectItem(i int) {
+	if i < 0 || i >= len(l.items) || i == *l.selected {
+		return
+	}
+	*l.selected = i
+	if l.onChange != nil {
+		l.onChange()
+	}
+}
+
+func (l *list) handle(u *ui) {
+	if u.clicked && l.hovered {
+		for i := range l.items {
+			if l.itemRect(i).Contains(u.mouse) {
+				l.selectItem(i)
+			}
+		}
+	}
+	if u.win.JustPressed(pixelgl.KeyUp) || u.win.Repeated(pixelgl.KeyUp) {
+		l.selectItem(*l.selected - 1)
+	}
+	if u.win.JustPressed(pixelgl.KeyDown) || u.win.Repeated(pixelgl.KeyDown) {
+		l.selectItem(*l.selected + 1)
+	}
+}
+
+func (l *list) draw(u *ui) {
+	_, border := widgetColors(&l.widgetBase)
+	u.rect(l.rect, uiBackground, border)
+	for i, item := range l.items {
+		r := l.itemRect(i)
+		if i == *l.selected {
+			u.rect(r, uiHover, nil)
+		}
+		u.label(item, r, false, textColor(&l.widgetBase))
+	}
+}
+
+// choice cycles through a number of options when it is activated. The
+// arrow keys step forward and backward.
+type choice struct {
+	widgetBase
+	text     string
+	options  []string
+	selected *int
+	onChange func()
+}
+
+func newChoice(text string, options []string, selected *int) *choice {
+	return &choice{text: text, options: options, selected: selected}
+}
+
+func (c *choice) focusable() bool {
+	return true
+}
+
+func (c *choice) step(dir int) {
+	if len(c.options) == 0 {
+		return
+	}
+	*c.selected = (*c.selected + dir + len(c.options)) % len(c.options)
+	if c.onChange != nil {
+		c.onChange()
+	}
+}
+
+func (c *choice) handle(u *ui) {
+	if u.activated(c) || u.win.JustPressed(pixelgl.KeyRight) {
+		c.step(1)
+	}
+	if u.win.JustPressed(pixelgl.KeyLeft) {
+		c.step(-1)
+	}
+}
+
+func (c *choice) draw(u *ui) {
+	fill, border := widgetColors(&c.widgetBase)
+	u.rect(c.rect, fill, border)
+	label := c.text
+	if *c.selected >= 0 && *c.selected < len(c.options) {
+		label += ": " + c.options[*c.selected]
+	}
+	u.label(label, c.rect, false, textColor(&c.widgetBase))
+}
+
+// keyInput lets the user pick a key. After it is activated the next key
+// that is pressed is stored in the button it points to. Escape cancels.
+type keyInput struct {
+	widgetBase
+	text      string
+	value     *pixelgl.Button
+	capturing bool
+	onChange  func()
+}
+
+func newKeyInput(text string, value *pixelgl.Button) *keyInput {
+	return &keyInput{text: text, value: value}
+}
+
+func (k *keyInput) focusable() bool {
+	return true
+}
+
+func (k *keyInput) handle(u *ui) {
+	if !k.capturing {
+		// The key that activates the widget must not be captured, so
+		// capturing starts with the next frame.
+		k.capturing = u.activated(k)
+		return
+	}
+	if u.win.JustPressed(pixelgl.KeyEscape) {
+		k.capturing = false
+		return
+	}
+	for b := pixelgl.KeySpace; b <= pixelgl.KeyLast; b++ {
+		if u.win.JustPressed(b) {
+			*k.value = b
+			k.capturing = false
+			if k.onChange != nil {
+				k.onChange()
+			}
+			return
+		}
+	}
+}
+
+func (k *keyInput) draw(u *ui) {
+	fill, border := widgetColors(&k.widgetBase)
+	u.rect(k.rect, fill, border)
+	key := k.value.String()
+	if k.capturing {
+		key = "press a key..."
+	}
+	u.label(k.text+": "+key, k.rect, false, textColor(&k.widgetBase))
+}
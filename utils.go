@@ -1,7 +1,11 @@
 package main
 
 import (
+	"io/ioutil"
+	"math"
 	"math/rand"
+	"os"
+	"path/filepath"
 
 	"github.com/faiface/pixel"
 )
@@ -17,6 +21,24 @@ func genPlanetParameters(sizes []int) (size, vel, dir float64) {
 	return
 }
 
+// genOrbit generates an orbit with the given distance to the anchor and a
+// random shape and phase. vel is the mean speed along the orbit. It is
+// ignored if the period follows from the anchor's mass (keplerOrbits).
+func genOrbit(dist, vel, dir, anchorMass, maxEccentricity float64) orbit {
+	period := 2 * math.Pi * dist / vel
+	if keplerOrbits {
+		period = keplerPeriod(dist, anchorMass)
+	}
+	return orbit{
+		semiMajor:    dist,
+		eccentricity: rand.Float64() * maxEccentricity,
+		periapsis:    rand.Float64() * 2 * math.Pi,
+		phase:        rand.Float64() * 2 * math.Pi,
+		period:       period,
+		dir:          dir,
+	}
+}
+
 // rotatePoint rotates point around anchor by angle omega (rad).
 func rotatePoint(anchor, point *pixel.Vec, omega float64) {
 	mat := pixel.IM.Rotated(*anchor, omega)
@@ -25,3 +47,31 @@ func rotatePoint(anchor, point *pixel.Vec, omega float64) {
 	point.X = npos.X
 	point.Y = npos.Y
 }
+
+// smoothstep interpolates smoothly from 0 to 1 when x moves from edge0 to edge1.
+func smoothstep(edge0, edge1, x float64) float64 {
+	t := math.Min(1, math.Max(0, (x-edge0)/(edge1-edge0)))
+	return t * t * (3 - 2*t)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and then
+// renames it to path. Readers never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}